@@ -0,0 +1,67 @@
+package api
+
+import (
+	"context"
+	"mgo-gin/app/storage"
+	"mgo-gin/config"
+	"mgo-gin/db"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type dependencyStatus struct {
+	Status    string `json:"status"`
+	LatencyMs int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+}
+
+// ApplyHealthAPI registra las sondas del orquestador en la raíz, fuera de /api/v1
+// y sin autenticación
+func ApplyHealthAPI(app *gin.Engine, resource *db.Resource, imageStorage storage.Storage, cfg config.HealthConfig) {
+	app.GET("/healthz", liveness())
+	app.GET("/readyz", readiness(resource, imageStorage, cfg))
+}
+
+func liveness() func(ctx *gin.Context) {
+	return func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, gin.H{"status": "ok"})
+	}
+}
+
+func readiness(resource *db.Resource, imageStorage storage.Storage, cfg config.HealthConfig) func(ctx *gin.Context) {
+	return func(ctx *gin.Context) {
+		checks := map[string]dependencyStatus{
+			"mongo": checkDependency(ctx.Request.Context(), cfg.Timeout, resource.Ping),
+		}
+		if cfg.CheckStorage {
+			checks["storage"] = checkDependency(ctx.Request.Context(), cfg.Timeout, imageStorage.Ping)
+		}
+
+		status, code := "ok", http.StatusOK
+		for _, check := range checks {
+			if check.Status != "ok" {
+				status, code = "unavailable", http.StatusServiceUnavailable
+			}
+		}
+		ctx.JSON(code, gin.H{
+			"status": status,
+			"checks": checks,
+		})
+	}
+}
+
+func checkDependency(parent context.Context, timeout time.Duration, ping func(context.Context) error) dependencyStatus {
+	ctx, cancel := context.WithTimeout(parent, timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := ping(ctx)
+	result := dependencyStatus{Status: "ok", LatencyMs: time.Since(start).Milliseconds()}
+	if err != nil {
+		result.Status = "unavailable"
+		result.Error = err.Error()
+	}
+	return result
+}
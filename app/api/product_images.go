@@ -0,0 +1,190 @@
+package api
+
+import (
+	"fmt"
+	"mgo-gin/app/model"
+	"mgo-gin/app/repository"
+	"mgo-gin/config"
+	"mgo-gin/middlewares"
+	"mgo-gin/utils/constant"
+	err2 "mgo-gin/utils/err"
+	"mime/multipart"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// applyProductImagesAPI registra la galería en /product/:productid/images. Ver
+// la galería es público; modificarla requiere rol de administrador.
+func applyProductImagesAPI(productRoute *gin.RouterGroup, productEntity repository.IProduct, maxImageBytes int64, limiter *middlewares.RateLimiter) {
+	imagesRoute := productRoute.Group("/:productid/images")
+	imagesRoute.GET("", getProductImages(productEntity))
+	imagesRoute.GET("/status", getProductImageStatus(productEntity))
+
+	adminRoute := imagesRoute.Group("")
+	adminRoute.Use(middlewares.AuthRequired())
+	adminRoute.Use(middlewares.RequireAuthorization(constant.ADMIN))
+	adminRoute.POST("", limiter.Limit(config.RateLimitPolicyUpload),
+		middlewares.MaxBodySize(maxImageBytes*model.MaxProductImages+formOverhead),
+		addProductImages(productEntity, maxImageBytes))
+	adminRoute.PUT("/order", reorderProductImages(productEntity))
+	adminRoute.PATCH("/:imageid", updateProductImage(productEntity))
+	adminRoute.POST("/:imageid/primary", setPrimaryProductImage(productEntity))
+	adminRoute.DELETE("/:imageid", deleteProductImage(productEntity))
+}
+
+// formOverhead deja margen para los campos de texto del formulario multipart
+const formOverhead = 1 << 20
+
+func checkImageSize(header *multipart.FileHeader, maxImageBytes int64) *err2.Error {
+	if header.Size <= maxImageBytes {
+		return nil
+	}
+	return &err2.Error{
+		Status:  http.StatusRequestEntityTooLarge,
+		Code:    err2.CodeTooLarge,
+		Message: fmt.Sprintf("La imagen %s supera el máximo de %d MB", header.Filename, maxImageBytes>>20),
+	}
+}
+
+// getProductImageStatus sirve para consultar si la imagen de un producto recién
+// creado ya se procesó
+func getProductImageStatus(productEntity repository.IProduct) func(ctx *gin.Context) {
+	return func(ctx *gin.Context) {
+		status, statusCode, err := productEntity.GetImageStatus(ctx.Request.Context(), ctx.Param("productid"))
+		if err != nil {
+			err2.Abort(ctx, err2.FromStatus(statusCode, err))
+			return
+		}
+		ctx.JSON(statusCode, status)
+	}
+}
+
+func getProductImages(productEntity repository.IProduct) func(ctx *gin.Context) {
+	return func(ctx *gin.Context) {
+		productImages, statusCode, err := productEntity.GetImages(ctx.Request.Context(), ctx.Param("productid"))
+		if err != nil {
+			err2.Abort(ctx, err2.FromStatus(statusCode, err))
+			return
+		}
+		ctx.JSON(statusCode, gin.H{"images": productImages})
+	}
+}
+
+// addProductImages recibe uno o más archivos en el campo "images" y, en el
+// mismo orden, sus textos alternativos en "alt"
+func addProductImages(productEntity repository.IProduct, maxImageBytes int64) func(ctx *gin.Context) {
+	return func(ctx *gin.Context) {
+		form, err := ctx.MultipartForm()
+		if err != nil {
+			err2.Abort(ctx, err2.Validation(err))
+			return
+		}
+		files := form.File["images"]
+		if len(files) == 0 {
+			err2.Abort(ctx, &err2.Error{
+				Status:  http.StatusBadRequest,
+				Code:    err2.CodeValidation,
+				Message: "Se requiere al menos una imagen",
+				Fields:  []err2.FieldError{{Field: "images", Message: "es obligatorio"}},
+			})
+			return
+		}
+		alts := form.Value["alt"]
+
+		uploads := []repository.ImageUpload{}
+		for i, header := range files {
+			alt := ""
+			if i < len(alts) {
+				alt = alts[i]
+			}
+			if len(alt) > 250 {
+				err2.Abort(ctx, &err2.Error{
+					Status:  http.StatusBadRequest,
+					Code:    err2.CodeValidation,
+					Message: "Datos inválidos",
+					Fields:  []err2.FieldError{{Field: fmt.Sprintf("alt[%d]", i), Message: "debe ser menor o igual a 250"}},
+				})
+				return
+			}
+			if err := checkImageSize(header, maxImageBytes); err != nil {
+				err2.Abort(ctx, err)
+				return
+			}
+			file, err := header.Open()
+			if err != nil {
+				err2.Abort(ctx, err2.FromStatus(http.StatusBadRequest, err))
+				return
+			}
+			defer file.Close()
+			uploads = append(uploads, repository.ImageUpload{File: file, Filename: header.Filename, Alt: alt})
+		}
+
+		product, statusCode, err := productEntity.AddImages(ctx.Request.Context(), ctx.Param("productid"), uploads)
+		if err != nil {
+			err2.Abort(ctx, err2.FromStatus(statusCode, err))
+			return
+		}
+		ctx.JSON(http.StatusCreated, gin.H{
+			"message": "Imágenes agregadas exitosamente",
+			"images":  product.Images,
+		})
+	}
+}
+
+func reorderProductImages(productEntity repository.IProduct) func(ctx *gin.Context) {
+	return func(ctx *gin.Context) {
+		var order model.IImageOrderForm
+		if err := ctx.ShouldBindJSON(&order); err != nil {
+			err2.Abort(ctx, err2.Validation(err))
+			return
+		}
+		product, statusCode, err := productEntity.ReorderImages(ctx.Request.Context(), ctx.Param("productid"), order.ImageIds)
+		if err != nil {
+			err2.Abort(ctx, err2.FromStatus(statusCode, err))
+			return
+		}
+		ctx.JSON(statusCode, gin.H{"images": product.Images})
+	}
+}
+
+func updateProductImage(productEntity repository.IProduct) func(ctx *gin.Context) {
+	return func(ctx *gin.Context) {
+		var form model.IImageAltForm
+		if err := ctx.ShouldBindJSON(&form); err != nil {
+			err2.Abort(ctx, err2.Validation(err))
+			return
+		}
+		productImage, statusCode, err := productEntity.UpdateImageAlt(ctx.Request.Context(), ctx.Param("productid"), ctx.Param("imageid"), form.Alt)
+		if err != nil {
+			err2.Abort(ctx, err2.FromStatus(statusCode, err))
+			return
+		}
+		ctx.JSON(statusCode, gin.H{"image": productImage})
+	}
+}
+
+func setPrimaryProductImage(productEntity repository.IProduct) func(ctx *gin.Context) {
+	return func(ctx *gin.Context) {
+		product, statusCode, err := productEntity.SetPrimaryImage(ctx.Request.Context(), ctx.Param("productid"), ctx.Param("imageid"))
+		if err != nil {
+			err2.Abort(ctx, err2.FromStatus(statusCode, err))
+			return
+		}
+		ctx.JSON(statusCode, gin.H{"images": product.Images})
+	}
+}
+
+func deleteProductImage(productEntity repository.IProduct) func(ctx *gin.Context) {
+	return func(ctx *gin.Context) {
+		product, statusCode, err := productEntity.DeleteImage(ctx.Request.Context(), ctx.Param("productid"), ctx.Param("imageid"))
+		if err != nil {
+			err2.Abort(ctx, err2.FromStatus(statusCode, err))
+			return
+		}
+		ctx.JSON(statusCode, gin.H{
+			"message": "Imagen eliminada exitosamente",
+			"images":  product.Images,
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"mgo-gin/app/model"
+	"mgo-gin/app/repository"
+	err2 "mgo-gin/utils/err"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+const mergePatchContentType = "application/merge-patch+json"
+
+// productETag identifica la versión de los datos editables del producto
+func productETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// ifMatchVersions lee las versiones aceptadas en If-Match. Sin cabecera o con *
+// devuelve nil (no se comprueba la versión). Los ETag débiles nunca coinciden
+// con una comparación fuerte, así que se ignoran.
+func ifMatchVersions(header string) []int64 {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return nil
+	}
+	versions := []int64{}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if strings.HasPrefix(tag, "W/") || len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+			continue
+		}
+		if version, err := strconv.ParseInt(tag[1:len(tag)-1], 10, 64); err == nil {
+			versions = append(versions, version)
+		}
+	}
+	return versions
+}
+
+// patchProduct aplica un JSON merge patch (RFC 7396) sobre título, precio y
+// descripción. Con If-Match solo se aplica si el producto no cambió.
+func patchProduct(productEntity repository.IProduct) func(ctx *gin.Context) {
+	return func(ctx *gin.Context) {
+		if contentType := ctx.ContentType(); contentType != mergePatchContentType && contentType != binding.MIMEJSON {
+			err2.Abort(ctx, err2.New(http.StatusUnsupportedMediaType, err2.CodeUnsupported, "Se esperaba "+mergePatchContentType))
+			return
+		}
+		patch, err := decodeProductPatch(ctx)
+		if err != nil {
+			err2.Abort(ctx, err)
+			return
+		}
+		saveProductFields(ctx, productEntity, patch)
+	}
+}
+
+// replaceProduct (PUT) reemplaza todos los campos editables del producto
+func replaceProduct(productEntity repository.IProduct) func(ctx *gin.Context) {
+	return func(ctx *gin.Context) {
+		var productData model.IReplaceProduct
+		if err := ctx.ShouldBindJSON(&productData); err != nil {
+			err2.Abort(ctx, err2.Validation(err))
+			return
+		}
+		saveProductFields(ctx, productEntity, model.IProductPatch{
+			Title:       &productData.Title,
+			Price:       &productData.Price,
+			Description: &productData.Description,
+		})
+	}
+}
+
+func saveProductFields(ctx *gin.Context, productEntity repository.IProduct, patch model.IProductPatch) {
+	expectedVersions := ifMatchVersions(ctx.GetHeader("If-Match"))
+	product, statusCode, err := productEntity.PatchProduct(ctx.Request.Context(), ctx.Param("productid"), patch, expectedVersions)
+	if err != nil {
+		err2.Abort(ctx, err2.FromStatus(statusCode, err))
+		return
+	}
+	ctx.Header("ETag", productETag(product.Version))
+	ctx.JSON(statusCode, gin.H{
+		"message": "Producto actualizado exitosamente",
+		"product": product,
+	})
+}
+
+// decodeProductPatch valida el merge patch: solo admite campos editables y no
+// permite borrarlos con null porque todos son obligatorios
+func decodeProductPatch(ctx *gin.Context) (model.IProductPatch, error) {
+	notObject := err2.New(http.StatusBadRequest, err2.CodeBadRequest, "El merge patch debe ser un objeto JSON")
+	var fields map[string]json.RawMessage
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&fields); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return model.IProductPatch{}, notObject
+		}
+		return model.IProductPatch{}, err2.Validation(err)
+	}
+	if fields == nil {
+		return model.IProductPatch{}, notObject
+	}
+
+	invalid := []err2.FieldError{}
+	for name, value := range fields {
+		switch name {
+		case "title", "price", "description":
+			if bytes.Equal(bytes.TrimSpace(value), []byte("null")) {
+				invalid = append(invalid, err2.FieldError{Field: name, Message: "es obligatorio, no se puede borrar"})
+			}
+		default:
+			invalid = append(invalid, err2.FieldError{Field: name, Message: "no se puede modificar"})
+		}
+	}
+	if len(invalid) > 0 {
+		return model.IProductPatch{}, &err2.Error{Status: http.StatusBadRequest, Code: err2.CodeValidation, Message: "Datos inválidos", Fields: invalid}
+	}
+
+	var patch model.IProductPatch
+	raw, _ := json.Marshal(fields)
+	if err := json.Unmarshal(raw, &patch); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return model.IProductPatch{}, &err2.Error{Status: http.StatusBadRequest, Code: err2.CodeValidation, Message: "Datos inválidos",
+				Fields: []err2.FieldError{{Field: typeErr.Field, Message: "no es válido"}}, Err: err}
+		}
+		return model.IProductPatch{}, err2.Validation(err)
+	}
+	if err := binding.Validator.ValidateStruct(&patch); err != nil {
+		return model.IProductPatch{}, err2.Validation(err)
+	}
+	return patch, nil
+}
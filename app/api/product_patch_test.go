@@ -0,0 +1,37 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIfMatchVersions(t *testing.T) {
+	tests := []struct {
+		header string
+		want   []int64
+	}{
+		{"", nil},
+		{"*", nil},
+		{" * ", nil},
+		{`"3"`, []int64{3}},
+		{`"3", "4"`, []int64{3, 4}},
+		{`W/"3"`, []int64{}},
+		{`W/"3", "5"`, []int64{5}},
+		{`3`, []int64{}},
+		{`"abc"`, []int64{}},
+		{`""`, []int64{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			if got := ifMatchVersions(tt.header); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ifMatchVersions(%q) = %#v, want %#v", tt.header, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProductETag(t *testing.T) {
+	if got := ifMatchVersions(productETag(42)); !reflect.DeepEqual(got, []int64{42}) {
+		t.Errorf("productETag does not round-trip through ifMatchVersions: %v", got)
+	}
+}
@@ -1,11 +1,17 @@
 package api
 
 import (
-	"mgo-gin/app/cloudinary"
+	"errors"
+	"io"
+	"mgo-gin/app/images"
 	"mgo-gin/app/model"
+	"mgo-gin/app/moderation"
 	"mgo-gin/app/repository"
+	"mgo-gin/app/storage"
+	"mgo-gin/config"
 	"mgo-gin/db"
 	"mgo-gin/middlewares"
+	"mgo-gin/utils/constant"
 	err2 "mgo-gin/utils/err"
 	"net/http"
 	"strconv"
@@ -13,73 +19,203 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func ApplyProductsAPI(app *gin.RouterGroup, resource *db.Resource, cldService *cloudinary.CloudinaryService) {
-	productEntity := repository.NewProductEntity(resource, cldService)
+func ApplyProductsAPI(app *gin.RouterGroup, resource *db.Resource, imageStorage storage.Storage, contentFilter *moderation.Pipeline, imageProcessor *images.Processor, limiter *middlewares.RateLimiter) {
+	productEntity := repository.NewProductEntity(resource, imageStorage, contentFilter, imageProcessor)
 	productRoute := app.Group("/product")
 
 	productRoute.GET("", getAllProduct(productEntity))
-	productRoute.POST("", createProduct(productEntity))
 	productRoute.GET("/:productid", getOneProduct(productEntity))
-	productRoute.POST("/:productid", updateProduct(productEntity))
+
+	// Crear y editar productos es solo para administradores; la autenticación va
+	// antes del rate limit para que la política de subidas cuente por usuario
+	adminRoute := productRoute.Group("")
+	adminRoute.Use(middlewares.AuthRequired())
+	adminRoute.Use(middlewares.RequireAuthorization(constant.ADMIN))
+	adminRoute.POST("", limiter.Limit(config.RateLimitPolicyUpload), middlewares.MaxBodySize(imageProcessor.MaxUploadBytes()+formOverhead), createProduct(productEntity, imageProcessor.MaxUploadBytes()))
+	adminRoute.PUT("/:productid", middlewares.MaxBodySize(formOverhead), replaceProduct(productEntity))
+	adminRoute.PATCH("/:productid", middlewares.MaxBodySize(formOverhead), patchProduct(productEntity))
+	adminRoute.POST("/:productid", middlewares.MaxBodySize(imageProcessor.MaxUploadBytes()+formOverhead), updateProduct(productEntity, imageProcessor.MaxUploadBytes()))
+
 	productRoute.POST("/:productid/add-comment", middlewares.AuthRequired(), addComment(productEntity))
+	productRoute.GET("/:productid/comments", getComments(productEntity))
+	productRoute.POST("/:productid/comments/:commentid/vote", middlewares.AuthRequired(), voteComment(productEntity))
+	productRoute.POST("/:productid/comments/:commentid/report", middlewares.AuthRequired(), reportComment(productEntity))
+	applyProductImagesAPI(productRoute, productEntity, imageProcessor.MaxUploadBytes(), limiter)
+
+	reviewRoute := app.Group("/review")
+	reviewRoute.Use(middlewares.AuthRequired())
+	reviewRoute.Use(middlewares.RequireAuthorization(constant.ADMIN))
+	reviewRoute.GET("/reported", getReportedComments(productEntity))
+	reviewRoute.POST("/reported/:productid/:commentid", resolveReportedComment(productEntity))
+}
+
+func getComments(productEntity repository.IProduct) func(ctx *gin.Context) {
+	return func(ctx *gin.Context) {
+		productid := ctx.Param("productid")
+		sortBy := ctx.DefaultQuery("sort", model.CommentSortNewest)
+		comments, statusCode, err := productEntity.GetComments(ctx.Request.Context(), productid, sortBy)
+		if err != nil {
+			err2.Abort(ctx, err2.FromStatus(statusCode, err))
+			return
+		}
+		ctx.JSON(statusCode, gin.H{
+			"comments": comments,
+			"total":    len(comments),
+		})
+	}
+}
+
+func voteComment(productEntity repository.IProduct) func(ctx *gin.Context) {
+	return func(ctx *gin.Context) {
+		var vote model.ICommentVoteForm
+		if err := ctx.ShouldBind(&vote); err != nil {
+			err2.Abort(ctx, err2.Validation(err))
+			return
+		}
+		comment, statusCode, err := productEntity.VoteComment(ctx.Request.Context(), ctx.Param("productid"), ctx.Param("commentid"), ctx.GetString("user_id"), *vote.Helpful)
+		if err != nil {
+			err2.Abort(ctx, err2.FromStatus(statusCode, err))
+			return
+		}
+		ctx.JSON(statusCode, gin.H{
+			"message": "Voto registrado exitosamente",
+			"comment": comment,
+		})
+	}
+}
+
+func reportComment(productEntity repository.IProduct) func(ctx *gin.Context) {
+	return func(ctx *gin.Context) {
+		var report model.ICommentReportForm
+		if err := ctx.ShouldBind(&report); err != nil {
+			err2.Abort(ctx, err2.Validation(err))
+			return
+		}
+		comment, statusCode, err := productEntity.ReportComment(ctx.Request.Context(), ctx.Param("productid"), ctx.Param("commentid"), ctx.GetString("user_id"), ctx.GetString("username"), report.Reason)
+		if err != nil {
+			err2.Abort(ctx, err2.FromStatus(statusCode, err))
+			return
+		}
+		ctx.JSON(statusCode, gin.H{
+			"message": "Reseña reportada exitosamente",
+			"comment": comment,
+		})
+	}
+}
 
+func getReportedComments(productEntity repository.IProduct) func(ctx *gin.Context) {
+	return func(ctx *gin.Context) {
+		reported, statusCode, err := productEntity.GetReportedComments(ctx.Request.Context())
+		if err != nil {
+			err2.Abort(ctx, err2.FromStatus(statusCode, err))
+			return
+		}
+		ctx.JSON(statusCode, gin.H{
+			"comments": reported,
+			"total":    len(reported),
+		})
+	}
+}
+
+func resolveReportedComment(productEntity repository.IProduct) func(ctx *gin.Context) {
+	return func(ctx *gin.Context) {
+		var resolve model.IResolveReportForm
+		if err := ctx.ShouldBind(&resolve); err != nil {
+			err2.Abort(ctx, err2.Validation(err))
+			return
+		}
+		product, statusCode, err := productEntity.ResolveReportedComment(ctx.Request.Context(), ctx.Param("productid"), ctx.Param("commentid"), resolve.Action)
+		if err != nil {
+			err2.Abort(ctx, err2.FromStatus(statusCode, err))
+			return
+		}
+		ctx.JSON(statusCode, gin.H{
+			"message": "Reporte resuelto exitosamente",
+			"product": product,
+		})
+	}
 }
 
 func addComment(productEntity repository.IProduct) func(ctx *gin.Context) {
 	return func(ctx *gin.Context) {
 		productid := ctx.Param("productid")
+		userId := ctx.GetString("user_id")
 		username, exists := ctx.Get("username")
 		if !exists {
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Nombre de usuario no encontrado en el contexto"})
+			err2.Abort(ctx, err2.New(http.StatusInternalServerError, err2.CodeInternal, "Nombre de usuario no encontrado en el contexto"))
 			return
 		}
 		email, exists := ctx.Get("email")
 		if !exists {
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Email no encontrado en el contexto"})
+			err2.Abort(ctx, err2.New(http.StatusInternalServerError, err2.CodeInternal, "Email no encontrado en el contexto"))
 			return
 		}
 
 		// Asegurarse de que son strings
 		usernameStr, ok := username.(string)
 		if !ok {
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Formato de nombre de usuario inválido en el contexto"})
+			err2.Abort(ctx, err2.New(http.StatusInternalServerError, err2.CodeInternal, "Formato de nombre de usuario inválido en el contexto"))
 			return
 		}
 		emailStr, ok := email.(string)
 		if !ok {
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Formato de email inválido en el contexto"})
+			err2.Abort(ctx, err2.New(http.StatusInternalServerError, err2.CodeInternal, "Formato de email inválido en el contexto"))
 			return
 		}
 		var comment model.IComment
 		if err := ctx.ShouldBind(&comment); err != nil {
-			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Datos de comentario inválidos: " + err.Error()})
+			err2.Abort(ctx, err2.Validation(err))
 			return
 		}
-		commentedProduct, statusCode, err := productEntity.AddComment(productid, usernameStr, emailStr, comment)
+		commentedProduct, statusCode, err := productEntity.AddComment(ctx.Request.Context(), productid, userId, usernameStr, emailStr, comment)
 		if err != nil {
-			ctx.JSON(statusCode, gin.H{"error": err2.GetErrorMessage(err)})
+			err2.Abort(ctx, err2.FromStatus(statusCode, err))
 			return
 		}
+		message := "Comentario agregado exitosamente"
+		if statusCode == http.StatusOK {
+			message = "Comentario actualizado exitosamente"
+		}
 		ctx.JSON(statusCode, gin.H{
-			"message": "Comentario agregado exitosamente",
+			"message": message,
 			"product": commentedProduct,
 		})
 	}
 }
 
-func updateProduct(productEntity repository.IProduct) func(ctx *gin.Context) {
+// updateProduct acepta opcionalmente un archivo "image" que reemplaza a la imagen principal
+func updateProduct(productEntity repository.IProduct, maxImageBytes int64) func(ctx *gin.Context) {
 	return func(ctx *gin.Context) {
 		productid := ctx.Param("productid")
 		var productData model.ICreateProduct
 		if err := ctx.ShouldBind(&productData); err != nil {
-			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Datos de producto inválidos: " + err.Error()})
+			err2.Abort(ctx, err2.Validation(err))
 			return
 		}
-		updatedProduct, statusCode, err := productEntity.UpdateProduct(productData, productid)
+
+		var imageFile io.ReadSeeker
+		imageFilename := ""
+		file, header, err := ctx.Request.FormFile("image")
+		switch {
+		case err == nil:
+			defer file.Close()
+			if err := checkImageSize(header, maxImageBytes); err != nil {
+				err2.Abort(ctx, err)
+				return
+			}
+			imageFile, imageFilename = file, header.Filename
+		case !errors.Is(err, http.ErrMissingFile) && !errors.Is(err, http.ErrNotMultipart):
+			err2.Abort(ctx, err2.Validation(err))
+			return
+		}
+
+		expectedVersions := ifMatchVersions(ctx.GetHeader("If-Match"))
+		updatedProduct, statusCode, err := productEntity.UpdateProduct(ctx.Request.Context(), productData, productid, imageFile, imageFilename, expectedVersions)
 		if err != nil {
-			ctx.JSON(statusCode, gin.H{"error": err2.GetErrorMessage(err)})
+			err2.Abort(ctx, err2.FromStatus(statusCode, err))
 			return
 		}
+		ctx.Header("ETag", productETag(updatedProduct.Version))
 		ctx.JSON(statusCode, gin.H{
 			"message": "Producto actualizado exitosamente",
 			"product": updatedProduct,
@@ -90,12 +226,14 @@ func updateProduct(productEntity repository.IProduct) func(ctx *gin.Context) {
 func getOneProduct(productEntity repository.IProduct) func(ctx *gin.Context) {
 	return func(ctx *gin.Context) {
 		productid := ctx.Param("productid")
-		product, statusCode, err := productEntity.GetOneProduct(productid)
-		response := map[string]interface{}{
-			"product": product,
-			"err":     err2.GetErrorMessage(err),
+		product, statusCode, err := productEntity.GetOneProduct(ctx.Request.Context(), productid)
+		if err != nil {
+			err2.Abort(ctx, err2.FromStatus(statusCode, err))
+			return
 		}
-		ctx.JSON(statusCode, response)
+		// El ETag se manda en If-Match al editar con PUT o PATCH
+		ctx.Header("ETag", productETag(product.Version))
+		ctx.JSON(statusCode, gin.H{"product": product})
 	}
 }
 
@@ -106,6 +244,7 @@ func getAllProduct(productEntity repository.IProduct) func(ctx *gin.Context) {
 		search := ctx.DefaultQuery("search", "")
 		maxPriceStr := ctx.DefaultQuery("maxPrice", "0")
 		minPriceStr := ctx.DefaultQuery("minPrice", "0")
+		sortBy := ctx.DefaultQuery("sort", model.ProductSortNewest)
 		page, err := strconv.Atoi(pageStr)
 		if err != nil || page < 1 {
 			page = 1 // Default to page 1 if invalid
@@ -113,14 +252,14 @@ func getAllProduct(productEntity repository.IProduct) func(ctx *gin.Context) {
 		maxPrice, err := strconv.ParseFloat(maxPriceStr, 64) // Convert to float64
 		if err != nil {
 			// Handle error appropriately, e.g., return bad request or use a default
-			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid maxPrice format"})
+			err2.Abort(ctx, err2.New(http.StatusBadRequest, err2.CodeBadRequest, "Formato de maxPrice inválido"))
 			return
 		}
 
 		minPrice, err := strconv.ParseFloat(minPriceStr, 64) // Convert to float64
 		if err != nil {
 			// Handle error appropriately
-			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid minPrice format"})
+			err2.Abort(ctx, err2.New(http.StatusBadRequest, err2.CodeBadRequest, "Formato de minPrice inválido"))
 			return
 		}
 		perPage, err := strconv.Atoi(perPageStr)
@@ -128,14 +267,10 @@ func getAllProduct(productEntity repository.IProduct) func(ctx *gin.Context) {
 			perPage = 10 // Default to 10 per page if invalid
 		}
 
-		list, totalCount, statusCode, err := productEntity.GetAll(page, perPage, search, maxPrice, minPrice)
-
-		var errorMsg interface{} // Use interface{} to be compatible with err2.GetErrorMessage or string
+		list, totalCount, statusCode, err := productEntity.GetAll(ctx.Request.Context(), page, perPage, search, maxPrice, minPrice, sortBy)
 		if err != nil {
-			// If you have a custom error handling package like `err2` as in your snippet:
-			// errorMsg = err2.GetErrorMessage(err)
-			// Otherwise, for a simple string error:
-			errorMsg = err.Error()
+			err2.Abort(ctx, err2.FromStatus(statusCode, err))
+			return
 		}
 
 		// Calculate total pages
@@ -152,39 +287,49 @@ func getAllProduct(productEntity repository.IProduct) func(ctx *gin.Context) {
 			"page":       page,
 			"perPage":    perPage,
 			"totalPages": totalPages,
-			"error":      errorMsg,
+			"sort":       sortBy,
 		}
 		ctx.JSON(statusCode, response)
 	}
 }
 
-func createProduct(productEntity repository.IProduct) func(ctx *gin.Context) {
+func createProduct(productEntity repository.IProduct, maxImageBytes int64) func(ctx *gin.Context) {
 	return func(ctx *gin.Context) {
 		var productData model.ICreateProduct
 
 		if err := ctx.ShouldBind(&productData); err != nil {
-			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Datos de producto inválidos: " + err.Error()})
+			err2.Abort(ctx, err2.Validation(err))
 			return
 		}
 
 		// Obtener el archivo de imagen del formulario
 		file, header, err := ctx.Request.FormFile("image") // "image" es el nombre del campo en el formulario
 		if err != nil {
-			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Se requiere un archivo de imagen: " + err.Error()})
+			err2.Abort(ctx, &err2.Error{
+				Status:  http.StatusBadRequest,
+				Code:    err2.CodeValidation,
+				Message: "Se requiere un archivo de imagen",
+				Fields:  []err2.FieldError{{Field: "image", Message: "es obligatorio"}},
+				Err:     err,
+			})
 			return
 		}
 		defer file.Close()
+		if err := checkImageSize(header, maxImageBytes); err != nil {
+			err2.Abort(ctx, err)
+			return
+		}
 
 		// Llamar al método del repositorio para crear el producto y subir la imagen
-		createdProduct, statusCode, err := productEntity.CreateOne(productData, file, header.Filename)
+		createdProduct, statusCode, err := productEntity.CreateOne(ctx.Request.Context(), productData, file, header.Filename)
 		if err != nil {
-			// Usa err2.GetErrorMessage si lo tienes y es apropiado aquí
-			ctx.JSON(statusCode, gin.H{"error": err2.GetErrorMessage(err)})
+			err2.Abort(ctx, err2.FromStatus(statusCode, err))
 			return
 		}
 
+		// La imagen se procesa en segundo plano: se consulta en /product/:productid/images/status
 		ctx.JSON(statusCode, gin.H{
-			"message": "Producto creado exitosamente",
+			"message": "Producto creado exitosamente, la imagen se está procesando",
 			"product": createdProduct,
 		})
 	}
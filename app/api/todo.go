@@ -6,7 +6,6 @@ import (
 	"mgo-gin/app/repository"
 	"mgo-gin/db"
 	err2 "mgo-gin/utils/err"
-	"net/http"
 
 	"github.com/gin-gonic/gin"
 )
@@ -33,12 +32,12 @@ func ApplyToDoAPI(app *gin.RouterGroup, resource *db.Resource) {
 // @Router /todo [get]
 func getAllToDo(toDoEntity repository.IToDo) func(ctx *gin.Context) {
 	return func(ctx *gin.Context) {
-		list, code, err := toDoEntity.GetAll()
-		response := map[string]interface{}{
-			"todo": list,
-			"err":  err2.GetErrorMessage(err),
+		list, code, err := toDoEntity.GetAll(ctx.Request.Context())
+		if err != nil {
+			err2.Abort(ctx, err2.FromStatus(code, err))
+			return
 		}
-		ctx.JSON(code, response)
+		ctx.JSON(code, gin.H{"todo": list})
 	}
 }
 
@@ -57,15 +56,15 @@ func createToDo(toDoEntity repository.IToDo) func(ctx *gin.Context) {
 
 		todoReq := model.ICreateToDo{}
 		if err := ctx.BindJSON(&todoReq); err != nil {
-			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"err": err.Error()})
+			err2.Abort(ctx, err2.Validation(err))
 			return
 		}
-		todo, code, err := toDoEntity.CreateOne(todoReq)
-		response := map[string]interface{}{
-			"todo": todo,
-			"err":  err2.GetErrorMessage(err),
+		todo, code, err := toDoEntity.CreateOne(ctx.Request.Context(), todoReq)
+		if err != nil {
+			err2.Abort(ctx, err2.FromStatus(code, err))
+			return
 		}
-		ctx.JSON(code, response)
+		ctx.JSON(code, gin.H{"todo": todo})
 	}
 }
 
@@ -82,12 +81,12 @@ func createToDo(toDoEntity repository.IToDo) func(ctx *gin.Context) {
 func getToDoById(toDoEntity repository.IToDo) func(ctx *gin.Context) {
 	return func(ctx *gin.Context) {
 		id := ctx.Param("id")
-		todo, code, err := toDoEntity.GetOneByID(id)
-		response := map[string]interface{}{
-			"todo": todo,
-			"err":  err2.GetErrorMessage(err),
+		todo, code, err := toDoEntity.GetOneByID(ctx.Request.Context(), id)
+		if err != nil {
+			err2.Abort(ctx, err2.FromStatus(code, err))
+			return
 		}
-		ctx.JSON(code, response)
+		ctx.JSON(code, gin.H{"todo": todo})
 	}
 }
 
@@ -107,14 +106,14 @@ func updateToDo(toDoEntity repository.IToDo) func(ctx *gin.Context) {
 		id := ctx.Param("id")
 		todoReq := form.ToDoForm{}
 		if err := ctx.Bind(&todoReq); err != nil {
-			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"err": err.Error()})
+			err2.Abort(ctx, err2.Validation(err))
 			return
 		}
-		todo, code, err := toDoEntity.Update(id, todoReq)
-		response := map[string]interface{}{
-			"todo": todo,
-			"err":  err2.GetErrorMessage(err),
+		todo, code, err := toDoEntity.Update(ctx.Request.Context(), id, todoReq)
+		if err != nil {
+			err2.Abort(ctx, err2.FromStatus(code, err))
+			return
 		}
-		ctx.JSON(code, response)
+		ctx.JSON(code, gin.H{"todo": todo})
 	}
 }
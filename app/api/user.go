@@ -4,19 +4,22 @@ import (
 	"mgo-gin/app/form"
 	"mgo-gin/app/model"
 	"mgo-gin/app/repository"
+	"mgo-gin/config"
 	"mgo-gin/db"
 	"mgo-gin/middlewares"
 	"mgo-gin/utils/bcrypt"
 	"mgo-gin/utils/constant"
 	err2 "mgo-gin/utils/err"
+	"mgo-gin/utils/metrics"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 )
 
-func ApplyUserAPI(app *gin.RouterGroup, resource *db.Resource) {
+func ApplyUserAPI(app *gin.RouterGroup, resource *db.Resource, limiter *middlewares.RateLimiter) {
 	userEntity := repository.NewUserEntity(resource)
 	authRoute := app.Group("")
+	authRoute.Use(limiter.Limit(config.RateLimitPolicyAuth))
 	authRoute.POST("/login", login(userEntity))
 	authRoute.POST("/sign-up", signUp(userEntity))
 
@@ -32,20 +35,25 @@ func login(userEntity repository.IUser) func(ctx *gin.Context) {
 
 		userRequest := form.User{}
 		if err := ctx.Bind(&userRequest); err != nil {
-			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"err": err.Error()})
+			err2.Abort(ctx, err2.Validation(err))
 			return
 		}
 
-		user, code, _ := userEntity.GetOneByUsername(userRequest.Username)
+		user, code, err := userEntity.GetOneByUsername(ctx.Request.Context(), userRequest.Username)
+		if err != nil && code != http.StatusNotFound {
+			err2.Abort(ctx, err2.FromStatus(code, err))
+			return
+		}
 
 		if (user == nil) || bcrypt.ComparePasswordAndHashedPassword(userRequest.Password, user.Password) != nil {
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"err": "Wrong username or password"})
+			metrics.Logins.WithLabelValues(metrics.LoginFailed).Inc()
+			err2.Abort(ctx, err2.New(http.StatusUnauthorized, err2.CodeUnauthorized, "Usuario o contraseña incorrectos"))
 			return
 		}
+		metrics.Logins.WithLabelValues(metrics.LoginSucceeded).Inc()
 		token := middlewares.GenerateJWTToken(*user)
 		response := map[string]interface{}{
 			"token": token,
-			"error": nil,
 			"user": model.ResponseUser{
 				Id:       user.Id,
 				Username: user.Username,
@@ -62,15 +70,15 @@ func signUp(userEntity repository.IUser) func(ctx *gin.Context) {
 
 		userRequest := form.User{}
 		if err := ctx.Bind(&userRequest); err != nil {
-			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"err": err.Error()})
+			err2.Abort(ctx, err2.Validation(err))
 			return
 		}
-		user, code, err := userEntity.CreateOne(userRequest)
-		response := map[string]interface{}{
-			"user":  user,
-			"error": err2.GetErrorMessage(err),
+		user, code, err := userEntity.CreateOne(ctx.Request.Context(), userRequest)
+		if err != nil {
+			err2.Abort(ctx, err2.FromStatus(code, err))
+			return
 		}
-		ctx.JSON(code, response)
+		ctx.JSON(code, gin.H{"user": user})
 	}
 }
 
@@ -85,11 +93,11 @@ func signUp(userEntity repository.IUser) func(ctx *gin.Context) {
 // @Router /user [get]
 func getAllUSer(userEntity repository.IUser) func(ctx *gin.Context) {
 	return func(ctx *gin.Context) {
-		list, code, err := userEntity.GetAll()
-		response := map[string]interface{}{
-			"users": list,
-			"error": err2.GetErrorMessage(err),
+		list, code, err := userEntity.GetAll(ctx.Request.Context())
+		if err != nil {
+			err2.Abort(ctx, err2.FromStatus(code, err))
+			return
 		}
-		ctx.JSON(code, response)
+		ctx.JSON(code, gin.H{"users": list})
 	}
 }
@@ -3,22 +3,35 @@ package cloudinary
 import (
 	"bytes"
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mgo-gin/config"
+	"mgo-gin/utils/metrics"
+	"mgo-gin/utils/tracing"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/cloudinary/cloudinary-go/v2"
+	"github.com/cloudinary/cloudinary-go/v2/api"
+	"github.com/cloudinary/cloudinary-go/v2/api/admin"
 	"github.com/cloudinary/cloudinary-go/v2/api/uploader"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type CloudinaryService struct {
-	cld *cloudinary.Cloudinary
+	cld           *cloudinary.Cloudinary
+	uploadTimeout time.Duration
 }
 
-func NewCloudinaryService(cloudName, apiKey, apiSecret string) (*CloudinaryService, error) {
-	cld, err := cloudinary.NewFromParams(cloudName, apiKey, apiSecret)
+func NewCloudinaryService(cfg config.CloudinaryConfig) (*CloudinaryService, error) {
+	cld, err := cloudinary.NewFromParams(cfg.CloudName, cfg.APIKey, cfg.APISecret)
 	if err != nil {
 		return nil, err
 	}
-	return &CloudinaryService{cld: cld}, nil
+	return &CloudinaryService{cld: cld, uploadTimeout: cfg.UploadTimeout}, nil
 }
 
 // Helper function to convert bool to *bool
@@ -26,21 +39,115 @@ func boolPtr(b bool) *bool {
 	return &b
 }
 
-func (s *CloudinaryService) UploadImage(file *bytes.Buffer, filename string) (string, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+// publicID arma un public id único a partir del nombre subido. Cloudinary ignora
+// UniqueFilename cuando se fija el public id, así que el sufijo aleatorio evita
+// que dos archivos con el mismo nombre se pisen.
+func publicID(filename string) string {
+	base := strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	if base == "" {
+		base = "image"
+	}
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix)
+	return base + "_" + hex.EncodeToString(suffix)
+}
+
+// UploadImage sube la imagen respetando la cancelación de ctx y el timeout de subida.
+// El formato guardado es el de la extensión de filename, así la URL termina en
+// la extensión real en vez de image.webp.jpg. Devuelve la URL y el public id
+// necesario para borrarla.
+func (s *CloudinaryService) UploadImage(ctx context.Context, file *bytes.Buffer, filename string) (string, string, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.uploadTimeout)
 	defer cancel()
+	ctx, span := tracing.Start(ctx, "cloudinary.upload",
+		attribute.String("cloudinary.filename", filename),
+		attribute.Int("cloudinary.bytes", file.Len()))
 
+	start := time.Now()
+	ext := filepath.Ext(filename)
 	uploadResult, err := s.cld.Upload.Upload(ctx, file, uploader.UploadParams{
-		PublicID:       filename,
-		ResourceType:   "auto",
-		Folder:         "images",
-		UseFilename:    boolPtr(true),
-		UniqueFilename: boolPtr(true),
+		PublicID:     publicID(filename),
+		Format:       strings.TrimPrefix(ext, "."),
+		ResourceType: "image",
+		Folder:       "images",
+		Overwrite:    boolPtr(false),
 	})
+	if err == nil && uploadResult.Error.Message != "" {
+		err = errors.New(uploadResult.Error.Message)
+	}
+	metrics.ObserveUpload("cloudinary", start, err)
+	tracing.End(span, err)
 
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 
-	return uploadResult.SecureURL, nil
+	return uploadResult.SecureURL, uploadResult.PublicID, nil
+}
+
+// DeleteImage borra una imagen subida e invalida la copia en la CDN. Borrar
+// una imagen que ya no existe no es un error.
+func (s *CloudinaryService) DeleteImage(ctx context.Context, publicID string) error {
+	ctx, cancel := context.WithTimeout(ctx, s.uploadTimeout)
+	defer cancel()
+	ctx, span := tracing.Start(ctx, "cloudinary.delete", attribute.String("cloudinary.public_id", publicID))
+
+	result, err := s.cld.Upload.Destroy(ctx, uploader.DestroyParams{
+		PublicID:     publicID,
+		ResourceType: "image",
+		Invalidate:   boolPtr(true),
+	})
+	if err == nil && result.Error.Message != "" {
+		err = errors.New(result.Error.Message)
+	}
+	if err == nil && result.Result != "ok" && result.Result != "not found" {
+		err = fmt.Errorf("cloudinary destroy %s: %s", publicID, result.Result)
+	}
+	tracing.End(span, err)
+	return err
+}
+
+// ListImages pagina las imágenes de la carpeta images con la Admin API; la clave
+// es el public id, igual que en UploadImage
+func (s *CloudinaryService) ListImages(ctx context.Context, olderThan time.Time, fn func(key string, url string) error) error {
+	cursor := ""
+	for {
+		result, err := s.cld.Admin.Assets(ctx, admin.AssetsParams{
+			AssetType:    api.Image,
+			DeliveryType: "upload",
+			Prefix:       "images/",
+			MaxResults:   500,
+			NextCursor:   cursor,
+		})
+		if err == nil && result.Error.Message != "" {
+			err = errors.New(result.Error.Message)
+		}
+		if err != nil {
+			return err
+		}
+		for _, asset := range result.Assets {
+			if !asset.CreatedAt.Before(olderThan) {
+				continue
+			}
+			if err := fn(asset.PublicID, asset.SecureURL); err != nil {
+				return err
+			}
+		}
+		if result.NextCursor == "" {
+			return nil
+		}
+		cursor = result.NextCursor
+	}
+}
+
+// Ping checks that the Cloudinary Admin API is reachable with the configured credentials
+func (s *CloudinaryService) Ping(ctx context.Context) error {
+	result, err := s.cld.Admin.Ping(ctx)
+	if err != nil {
+		return err
+	}
+	if result.Error.Message != "" {
+		return errors.New(result.Error.Message)
+	}
+	return nil
 }
@@ -0,0 +1,28 @@
+package cloudinary
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPublicIDIsUnique(t *testing.T) {
+	tests := []struct {
+		filename string
+		prefix   string
+	}{
+		{"photo_w400.webp", "photo_w400_"},
+		{"dir/photo.jpg", "photo_"},
+		{".jpg", "image_"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filename, func(t *testing.T) {
+			first, second := publicID(tt.filename), publicID(tt.filename)
+			if !strings.HasPrefix(first, tt.prefix) {
+				t.Errorf("publicID(%q) = %q, want prefix %q", tt.filename, first, tt.prefix)
+			}
+			if first == second {
+				t.Errorf("publicID(%q) returned %q twice", tt.filename, first)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package images
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+
+	"github.com/disintegration/imaging"
+	_ "golang.org/x/image/webp"
+)
+
+// Tipos aceptados según http.DetectContentType, sin confiar en la extensión ni
+// en el Content-Type que manda el cliente
+var allowedTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+var (
+	ErrUnsupportedType = errors.New("el archivo no es una imagen JPEG, PNG, GIF o WebP")
+	ErrInvalidImage    = errors.New("la imagen está dañada o no se pudo leer")
+	ErrImageTooLarge   = errors.New("la imagen es demasiado grande")
+)
+
+// Inspect valida una imagen subida sin decodificarla y deja r al principio:
+//   - detecta el tipo por su contenido antes de decodificar nada
+//   - lee solo la cabecera con DecodeConfig para rechazar imágenes con demasiados
+//     píxeles (bombas de descompresión) sin reservar memoria para ellas
+func Inspect(r io.ReadSeeker, maxPixels int) (string, error) {
+	head := make([]byte, 512)
+	n, err := io.ReadFull(r, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return "", ErrInvalidImage
+	}
+	contentType := http.DetectContentType(head[:n])
+	if !allowedTypes[contentType] {
+		return contentType, ErrUnsupportedType
+	}
+
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return contentType, err
+	}
+	cfg, _, err := image.DecodeConfig(r)
+	if err != nil {
+		return contentType, ErrInvalidImage
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > maxPixels {
+		return contentType, fmt.Errorf("%w: %dx%d px, máximo %d megapíxeles", ErrImageTooLarge, cfg.Width, cfg.Height, maxPixels/1_000_000)
+	}
+
+	_, err = r.Seek(0, io.SeekStart)
+	return contentType, err
+}
+
+// Decode valida la imagen con Inspect y la decodifica corrigiendo la orientación
+// EXIF. Los metadatos (EXIF, GPS) no sobreviven porque la imagen se vuelve a
+// codificar desde los píxeles.
+func Decode(r io.ReadSeeker, maxPixels int) (image.Image, string, error) {
+	contentType, err := Inspect(r, maxPixels)
+	if err != nil {
+		return nil, contentType, err
+	}
+	img, err := imaging.Decode(r, imaging.AutoOrientation(true))
+	if err != nil {
+		return nil, contentType, ErrInvalidImage
+	}
+	return img, contentType, nil
+}
+
+// ErrorStatus traduce los errores de Decode al código HTTP que corresponde
+func ErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrUnsupportedType):
+		return http.StatusUnsupportedMediaType
+	case errors.Is(err, ErrImageTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, ErrInvalidImage):
+		return http.StatusUnprocessableEntity
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,62 @@
+package images
+
+import (
+	"fmt"
+	"image"
+	"sort"
+
+	"github.com/disintegration/imaging"
+)
+
+const ThumbnailName = "thumb"
+
+// Derivative es una versión redimensionada de la imagen original lista para codificar
+type Derivative struct {
+	Name  string
+	Image image.Image
+}
+
+func (d Derivative) Width() int {
+	return d.Image.Bounds().Dx()
+}
+
+func (d Derivative) Height() int {
+	return d.Image.Bounds().Dy()
+}
+
+// Derive genera un derivado por cada ancho de widths, de menor a mayor y sin
+// agrandar la imagen, y un recorte cuadrado de thumbnailSize px para
+// miniaturas si thumbnailSize > 0
+func Derive(img image.Image, widths []int, thumbnailSize int) []Derivative {
+	sorted := append([]int(nil), widths...)
+	sort.Ints(sorted)
+
+	// El derivado más grande es el mayor ancho configurado o el original si es más chico
+	original := img.Bounds().Dx()
+	largest := original
+	if n := len(sorted); n > 0 && sorted[n-1] < original {
+		largest = sorted[n-1]
+	}
+
+	derivatives := []Derivative{}
+	for _, width := range sorted {
+		if width >= largest {
+			break
+		}
+		derivatives = append(derivatives, Derivative{
+			Name:  fmt.Sprintf("w%d", width),
+			Image: imaging.Resize(img, width, 0, imaging.Lanczos),
+		})
+	}
+	if largest < original {
+		img = imaging.Resize(img, largest, 0, imaging.Lanczos)
+	}
+	derivatives = append(derivatives, Derivative{Name: fmt.Sprintf("w%d", largest), Image: img})
+	if thumbnailSize > 0 {
+		derivatives = append(derivatives, Derivative{
+			Name:  ThumbnailName,
+			Image: imaging.Fill(img, thumbnailSize, thumbnailSize, imaging.Center, imaging.Lanczos),
+		})
+	}
+	return derivatives
+}
@@ -0,0 +1,65 @@
+package images
+
+import (
+	"image"
+	"reflect"
+	"testing"
+)
+
+func TestDerive(t *testing.T) {
+	tests := []struct {
+		name      string
+		width     int
+		widths    []int
+		thumbnail int
+		want      []string
+		wantSizes [][2]int
+	}{
+		{
+			name:      "larger than every width",
+			width:     2000,
+			widths:    []int{800, 400, 1200},
+			want:      []string{"w400", "w800", "w1200"},
+			wantSizes: [][2]int{{400, 200}, {800, 400}, {1200, 600}},
+		},
+		{
+			name:      "never upscales",
+			width:     600,
+			widths:    []int{400, 800, 1200},
+			want:      []string{"w400", "w600"},
+			wantSizes: [][2]int{{400, 200}, {600, 300}},
+		},
+		{
+			name:      "smaller than every width",
+			width:     100,
+			widths:    []int{400, 800},
+			want:      []string{"w100"},
+			wantSizes: [][2]int{{100, 50}},
+		},
+		{
+			name:      "with thumbnail",
+			width:     1000,
+			widths:    []int{400},
+			thumbnail: 150,
+			want:      []string{"w400", ThumbnailName},
+			wantSizes: [][2]int{{400, 200}, {150, 150}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := image.NewRGBA(image.Rect(0, 0, tt.width, tt.width/2))
+			derivatives := Derive(img, tt.widths, tt.thumbnail)
+			names, sizes := []string{}, [][2]int{}
+			for _, d := range derivatives {
+				names = append(names, d.Name)
+				sizes = append(sizes, [2]int{d.Width(), d.Height()})
+			}
+			if !reflect.DeepEqual(names, tt.want) {
+				t.Errorf("names = %v, want %v", names, tt.want)
+			}
+			if !reflect.DeepEqual(sizes, tt.wantSizes) {
+				t.Errorf("sizes = %v, want %v", sizes, tt.wantSizes)
+			}
+		})
+	}
+}
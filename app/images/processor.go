@@ -0,0 +1,87 @@
+package images
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"io"
+	"mgo-gin/config"
+)
+
+// Format describe el formato en que se guardó una imagen; la extensión del
+// archivo subido siempre sale de aquí para que coincida con el contenido
+type Format struct {
+	Name        string
+	Ext         string
+	ContentType string
+}
+
+var (
+	WebP = Format{Name: config.ImageFormatWebP, Ext: ".webp", ContentType: "image/webp"}
+	JPEG = Format{Name: config.ImageFormatJPEG, Ext: ".jpg", ContentType: "image/jpeg"}
+)
+
+// Processor genera los derivados de una imagen y los codifica según ImagesConfig
+type Processor struct {
+	format        Format
+	quality       int
+	lossless      bool
+	widths        []int
+	thumbnailSize int
+	maxPixels     int
+	maxBytes      int64
+}
+
+// NewProcessor codifica en el formato de cfg; config.Load ya rechazó WebP si el
+// binario no se compiló con libwebp (-tags webp)
+func NewProcessor(cfg config.ImagesConfig) *Processor {
+	format := JPEG
+	if cfg.Format == config.ImageFormatWebP && config.WebPSupported {
+		format = WebP
+	}
+	return &Processor{
+		format:        format,
+		quality:       cfg.Quality,
+		lossless:      cfg.Lossless,
+		widths:        cfg.Widths,
+		thumbnailSize: cfg.ThumbnailSize,
+		maxPixels:     cfg.MaxMegapixels * 1_000_000,
+		maxBytes:      cfg.MaxUploadBytes(),
+	}
+}
+
+func (p *Processor) Format() Format {
+	return p.format
+}
+
+// MaxUploadBytes es el tamaño máximo aceptado para cada archivo
+func (p *Processor) MaxUploadBytes() int64 {
+	return p.maxBytes
+}
+
+// Inspect valida la imagen con el límite de píxeles configurado sin decodificarla
+func (p *Processor) Inspect(r io.ReadSeeker) (string, error) {
+	return Inspect(r, p.maxPixels)
+}
+
+// Decode valida y decodifica la imagen con el límite de píxeles configurado
+func (p *Processor) Decode(r io.ReadSeeker) (image.Image, string, error) {
+	return Decode(r, p.maxPixels)
+}
+
+// Derive genera los derivados configurados de img
+func (p *Processor) Derive(img image.Image) []Derivative {
+	return Derive(img, p.widths, p.thumbnailSize)
+}
+
+// Encode codifica img en el formato configurado
+func (p *Processor) Encode(img image.Image) (*bytes.Buffer, Format, error) {
+	var buf bytes.Buffer
+	var err error
+	if p.format == WebP {
+		err = encodeWebP(&buf, img, p.quality, p.lossless)
+	} else {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: p.quality})
+	}
+	return &buf, p.format, err
+}
@@ -0,0 +1,26 @@
+//go:build webp && cgo
+
+package images
+
+import (
+	"image"
+	"io"
+
+	"github.com/kolesa-team/go-webp/encoder"
+	"github.com/kolesa-team/go-webp/webp"
+)
+
+func encodeWebP(w io.Writer, img image.Image, quality int, lossless bool) error {
+	var options *encoder.Options
+	var err error
+	if lossless {
+		// En modo sin pérdida la calidad indica el esfuerzo de compresión (0-9)
+		options, err = encoder.NewLosslessEncoderOptions(encoder.PresetDefault, quality*9/100)
+	} else {
+		options, err = encoder.NewLossyEncoderOptions(encoder.PresetDefault, float32(quality))
+	}
+	if err != nil {
+		return err
+	}
+	return webp.Encode(w, img, options)
+}
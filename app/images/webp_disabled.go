@@ -0,0 +1,13 @@
+//go:build !webp || !cgo
+
+package images
+
+import (
+	"errors"
+	"image"
+	"io"
+)
+
+func encodeWebP(io.Writer, image.Image, int, bool) error {
+	return errors.New("WebP encoding is not available in this build")
+}
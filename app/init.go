@@ -1,59 +1,137 @@
 package app
 
 import (
+	"context"
 	"mgo-gin/app/api"
-	"mgo-gin/app/cloudinary"
+	"mgo-gin/app/images"
+	"mgo-gin/app/jobs"
+	"mgo-gin/app/moderation"
+	"mgo-gin/app/repository"
+	"mgo-gin/app/storage"
+	"mgo-gin/config"
 	"mgo-gin/db"
 	"mgo-gin/middlewares"
-	"os"
+	"mgo-gin/utils/logger"
+	"mgo-gin/utils/metrics"
+	"mgo-gin/utils/tracing"
+	"net"
+	"net/http"
+	"os/signal"
+	"syscall"
 	"time"
 
-	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
 type Routes struct {
 }
 
 func (app Routes) StartGin() {
-	r := gin.Default()
-	// Configure CORS
-	r.Use(gin.Logger())
-	r.Use(middlewares.NewRecovery())
-	r.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"https://nay-sdreams-production.up.railway.app"},
-		AllowMethods:     []string{"*"},
-		AllowHeaders:     []string{"*"},
-		ExposeHeaders:    []string{"*"},
-		AllowCredentials: false,
-		MaxAge:           50 * time.Second,
-	}))
+	cfg, err := config.Load()
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	logger.Setup(cfg.Log)
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing, cfg.Env)
+	if err != nil {
+		logrus.Fatalf("Failed to initialize tracing: %v", err)
+	}
+	if cfg.IsProduction() {
+		gin.SetMode(gin.ReleaseMode)
+	}
+	middlewares.SetJWTConfig(cfg.JWT)
+
+	r := gin.New()
+	// Sin proxies de confianza cualquiera podría falsear X-Forwarded-For y
+	// saltarse el rate limit por IP
+	if len(cfg.TrustedProxies) > 0 {
+		if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+			logrus.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+		}
+	}
+	r.Use(middlewares.NewRequestID())
+	r.Use(otelgin.Middleware(cfg.Tracing.ServiceName, otelgin.WithFilter(func(req *http.Request) bool {
+		// Las sondas y el scrapeo de métricas solo generarían ruido en las trazas
+		switch req.URL.Path {
+		case "/metrics", "/healthz", "/readyz":
+			return false
+		}
+		return true
+	})))
+	r.Use(middlewares.NewLogger())
+	r.Use(middlewares.NewMetrics())
+	r.Use(middlewares.NewRecovery("./error.tmpl"))
+	r.Use(middlewares.ErrorHandler())
+	r.Use(middlewares.NewCors(cfg.CORS))
 	r.GET("swagger/*any", middlewares.NewSwagger())
+	r.GET("/metrics", metrics.Handler())
 
+	limiter := middlewares.NewRateLimiter(cfg.RateLimit, middlewares.NewMemoryRateLimitStore())
 	publicRoute := r.Group("/api/v1")
-	resource, err := db.InitResource()
+	publicRoute.Use(limiter.Limit(config.RateLimitPolicyDefault))
+	resource, err := db.InitResource(cfg.Mongo)
 	if err != nil {
-		logrus.Error(err)
+		logrus.Fatal(err)
 	}
-	defer resource.Close()
-	cloudName := os.Getenv("CLOUDINARY_CLOUD_NAME")
-	apiKey := os.Getenv("CLOUDINARY_API_KEY")
-	apiSecret := os.Getenv("CLOUDINARY_API_SECRET")
 	//r.Static("/template/css", "./template/css")
 	//r.Static("/template/images", "./template/images")
 	r.Static("/template", "./template")
-	if cloudName == "" || apiKey == "" || apiSecret == "" {
-		logrus.Fatal("Cloudinary environment variables not set (CLOUDINARY_CLOUD_NAME, CLOUDINARY_API_KEY, CLOUDINARY_API_SECRET)")
-	}
 
-	cldService, err := cloudinary.NewCloudinaryService(cloudName, apiKey, apiSecret)
+	imageStorage, err := storage.New(cfg)
 	if err != nil {
-		logrus.Fatalf("Failed to initialize Cloudinary service: %v", err)
+		logrus.Fatalf("Failed to initialize %s storage: %v", cfg.Storage.Driver, err)
+	}
+	if local, ok := imageStorage.(*storage.LocalStorage); ok {
+		r.Static(local.URLPrefix(), local.Dir())
 	}
+	logrus.Infof("Storing product images in %s", cfg.Storage.Driver)
+	api.ApplyHealthAPI(r, resource, imageStorage, cfg.Health)
+
+	contentFilter := moderation.NewPipelineFromConfig(moderation.ConfigFromReviews(cfg.Reviews))
 	// Rutas públicas (sin autenticación)
-	api.ApplyUserAPI(publicRoute, resource)
-	api.ApplyProductsAPI(publicRoute, resource, cldService)
+	api.ApplyUserAPI(publicRoute, resource, limiter)
+	imageProcessor := images.NewProcessor(cfg.Images)
+	api.ApplyProductsAPI(publicRoute, resource, imageStorage, contentFilter, imageProcessor, limiter)
+
+	// Migraciones e índices antes de aceptar peticiones, con su propio límite:
+	// recorren colecciones enteras y no caben en MONGO_QUERY_TIMEOUT
+	imageJobs := repository.NewImageJobEntity(resource)
+	productEntity := repository.NewProductEntity(resource, imageStorage, contentFilter, imageProcessor)
+	migrationCtx, cancelMigrations := context.WithTimeout(context.Background(), cfg.Mongo.MigrationTimeout)
+	if err := productEntity.MigrateCommentIds(migrationCtx); err != nil {
+		logrus.Errorf("Error migrating comment ids: %v", err)
+	}
+	if err := productEntity.MigrateLegacyImages(migrationCtx); err != nil {
+		logrus.Errorf("Error migrating product images: %v", err)
+	}
+	if err := productEntity.MigrateReviewCounts(migrationCtx); err != nil {
+		logrus.Errorf("Error migrating review counts: %v", err)
+	}
+	if err := productEntity.EnsureIndexes(migrationCtx); err != nil {
+		logrus.Errorf("Error creating product indexes: %v", err)
+	}
+	if err := imageJobs.EnsureIndexes(migrationCtx); err != nil {
+		logrus.Errorf("Error creating image job indexes: %v", err)
+	}
+	cancelMigrations()
+
+	// Los workers procesan en segundo plano las imágenes de los productos nuevos
+	imageWorkers := jobs.NewPool(cfg.ImageJobs, imageJobs, productEntity.ProcessImageJob)
+	imageWorkers.Start()
+
+	// Limpieza periódica de imágenes que quedaron en el storage sin producto
+	stopReconcile := func() {}
+	if reconcile := cfg.Storage.Reconcile; reconcile.Interval > 0 {
+		stopReconcile = jobs.Every("storage reconcile", reconcile.Interval, func(ctx context.Context) error {
+			orphans, err := productEntity.ReconcileStorage(ctx, time.Now().Add(-reconcile.GracePeriod), reconcile.DryRun)
+			if err == nil {
+				logrus.Infof("Storage reconcile found %d orphaned images", orphans)
+			}
+			return err
+		})
+	}
 	// Rutas protegidas (con autenticación)
 	protectedRoute := publicRoute.Group("")
 	protectedRoute.Use(middlewares.AuthRequired())
@@ -62,5 +140,43 @@ func (app Routes) StartGin() {
 		context.File("./template/index.html")
 	})
 
-	r.Run(":8080")
+	// Todas las peticiones heredan baseCtx: si el apagado no termina a tiempo se
+	// cancela y las consultas a Mongo y subidas de imágenes pendientes se abortan
+	baseCtx, cancelRequests := context.WithCancel(context.Background())
+	defer cancelRequests()
+	srv := &http.Server{
+		Addr:        ":" + cfg.Port,
+		Handler:     r,
+		BaseContext: func(net.Listener) context.Context { return baseCtx },
+	}
+	go func() {
+		logrus.Infof("Listening on %s", srv.Addr)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logrus.Fatalf("Server failed: %v", err)
+		}
+	}()
+
+	// Al recibir SIGINT/SIGTERM se dejan de aceptar conexiones, se esperan las
+	// peticiones en curso y los jobs de imágenes, y después se cierra la conexión con MongoDB
+	stop, cancelSignals := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancelSignals()
+	<-stop.Done()
+	logrus.Info("Shutting down server")
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		logrus.Errorf("Server forced to shutdown: %v", err)
+		cancelRequests()
+	}
+	stopReconcile()
+	if err := imageWorkers.Stop(ctx); err != nil {
+		logrus.Errorf("Image workers forced to stop: %v", err)
+	}
+	if err := resource.Close(ctx); err != nil {
+		logrus.Errorf("Error closing db connections: %v", err)
+	}
+	if err := shutdownTracing(ctx); err != nil {
+		logrus.Errorf("Error flushing traces: %v", err)
+	}
 }
@@ -0,0 +1,37 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Every ejecuta fn cada interval en segundo plano, la primera vez tras un
+// intervalo. Cada ejecución tiene interval como límite. La función devuelta
+// detiene el ciclo y espera a que termine la ejecución en curso.
+func Every(name string, interval time.Duration, fn func(ctx context.Context) error) (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			runCtx, cancelRun := context.WithTimeout(ctx, interval)
+			if err := fn(runCtx); err != nil && ctx.Err() == nil {
+				logrus.WithError(err).Errorf("Periodic job %s failed", name)
+			}
+			cancelRun()
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
@@ -0,0 +1,154 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"mgo-gin/app/model"
+	"mgo-gin/config"
+	"mgo-gin/utils/logger"
+	"mgo-gin/utils/metrics"
+	"mgo-gin/utils/tracing"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Queue es la cola de jobs de imágenes. Claim toma el siguiente job pendiente (o
+// uno cuyo worker dejó vencer el lease) y lo reserva durante lease.
+type Queue interface {
+	Claim(ctx context.Context, lease time.Duration) (model.IImageJob, bool, error)
+	Complete(ctx context.Context, job model.IImageJob) error
+	Retry(ctx context.Context, job model.IImageJob, runAt time.Time, err error) error
+	Fail(ctx context.Context, job model.IImageJob, err error) error
+}
+
+// Handler procesa un job. Los errores se reintentan salvo los marcados con Permanent.
+type Handler func(ctx context.Context, job model.IImageJob) error
+
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marca un error que no se arregla reintentando, por ejemplo una
+// imagen dañada: el job falla sin gastar los intentos que le quedan
+func Permanent(err error) error {
+	return permanentError{err: err}
+}
+
+// Pool reparte los jobs de la cola entre cfg.Workers goroutines
+type Pool struct {
+	cfg     config.ImageJobsConfig
+	queue   Queue
+	handler Handler
+	// stopClaiming frena la búsqueda de jobs y cancelJobs corta los que están en curso
+	stopClaiming context.CancelFunc
+	cancelJobs   context.CancelFunc
+	wg           sync.WaitGroup
+}
+
+func NewPool(cfg config.ImageJobsConfig, queue Queue, handler Handler) *Pool {
+	return &Pool{cfg: cfg, queue: queue, handler: handler}
+}
+
+// Start arranca los workers; siguen buscando jobs hasta que se llama a Stop
+func (p *Pool) Start() {
+	claimCtx, stopClaiming := context.WithCancel(context.Background())
+	jobsCtx, cancelJobs := context.WithCancel(context.Background())
+	p.stopClaiming, p.cancelJobs = stopClaiming, cancelJobs
+	for i := 0; i < p.cfg.Workers; i++ {
+		p.wg.Add(1)
+		go p.work(claimCtx, jobsCtx)
+	}
+	logrus.Infof("Started %d image workers", p.cfg.Workers)
+}
+
+// Stop deja de tomar jobs y espera a los que están en curso hasta que ctx
+// vence; entonces los cancela y vuelven a la cola para el próximo arranque.
+func (p *Pool) Stop(ctx context.Context) error {
+	if p.stopClaiming == nil {
+		return nil
+	}
+	p.stopClaiming()
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		p.cancelJobs()
+		return nil
+	case <-ctx.Done():
+		p.cancelJobs()
+		<-done
+		return ctx.Err()
+	}
+}
+
+func (p *Pool) work(claimCtx context.Context, jobsCtx context.Context) {
+	defer p.wg.Done()
+	for claimCtx.Err() == nil {
+		job, ok, err := p.queue.Claim(claimCtx, p.cfg.Lease)
+		if err != nil && claimCtx.Err() == nil {
+			logrus.WithError(err).Error("Error claiming image job")
+		}
+		if !ok {
+			select {
+			case <-claimCtx.Done():
+			case <-time.After(p.cfg.PollInterval):
+			}
+			continue
+		}
+		p.run(jobsCtx, job)
+	}
+}
+
+func (p *Pool) run(ctx context.Context, job model.IImageJob) {
+	jobCtx, cancel := context.WithTimeout(ctx, p.cfg.Lease)
+	defer cancel()
+	jobCtx, span := tracing.Start(jobCtx, "image_job.process",
+		attribute.String("job.id", job.Id.Hex()),
+		attribute.String("product.id", job.ProductId.Hex()),
+		attribute.Int("job.attempt", job.Attempts))
+	err := p.handler(jobCtx, job)
+	tracing.End(span, err)
+
+	// La cola se actualiza aunque el pool se esté apagando
+	queueCtx := context.WithoutCancel(jobCtx)
+	log := logger.FromContext(queueCtx).WithField("job_id", job.Id.Hex()).WithField("product_id", job.ProductId.Hex()).WithField("attempt", job.Attempts)
+	var permanent permanentError
+	switch {
+	case err == nil:
+		metrics.ImageJobs.WithLabelValues(metrics.JobDone).Inc()
+		err = p.queue.Complete(queueCtx, job)
+	case ctx.Err() != nil && errors.Is(err, context.Canceled):
+		// Cortado por el apagado: vuelve a la cola enseguida
+		log.Warn("Image job interrupted by shutdown")
+		err = p.queue.Retry(queueCtx, job, time.Now(), err)
+	case errors.As(err, &permanent) || job.Attempts >= p.cfg.MaxAttempts:
+		log.WithError(err).Error("Image job failed")
+		metrics.ImageJobs.WithLabelValues(metrics.JobFailed).Inc()
+		err = p.queue.Fail(queueCtx, job, err)
+	default:
+		runAt := time.Now().Add(p.backoff(job.Attempts))
+		log.WithError(err).Warnf("Image job failed, retrying at %s", runAt.Format(time.RFC3339))
+		metrics.ImageJobs.WithLabelValues(metrics.JobRetried).Inc()
+		err = p.queue.Retry(queueCtx, job, runAt, err)
+	}
+	if err != nil {
+		log.WithError(err).Error("Error updating image job")
+	}
+}
+
+// backoff duplica la espera en cada intento
+func (p *Pool) backoff(attempts int) time.Duration {
+	if attempts < 1 {
+		attempts = 1
+	}
+	return p.cfg.RetryBackoff * time.Duration(1<<uint(attempts-1))
+}
@@ -0,0 +1,128 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"mgo-gin/app/model"
+	"mgo-gin/config"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// fakeQueue entrega un único job y registra cómo terminó cada intento
+type fakeQueue struct {
+	mu       sync.Mutex
+	job      model.IImageJob
+	ready    bool
+	outcomes []string
+	done     chan struct{}
+}
+
+func newFakeQueue() *fakeQueue {
+	return &fakeQueue{job: model.IImageJob{Id: primitive.NewObjectID()}, ready: true, done: make(chan struct{})}
+}
+
+func (q *fakeQueue) Claim(ctx context.Context, lease time.Duration) (model.IImageJob, bool, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if !q.ready {
+		return model.IImageJob{}, false, nil
+	}
+	q.ready = false
+	q.job.Attempts++
+	return q.job, true, nil
+}
+
+func (q *fakeQueue) record(outcome string, again bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.outcomes = append(q.outcomes, outcome)
+	q.ready = again
+	if !again {
+		close(q.done)
+	}
+}
+
+func (q *fakeQueue) Complete(ctx context.Context, job model.IImageJob) error {
+	q.record("done", false)
+	return nil
+}
+
+func (q *fakeQueue) Retry(ctx context.Context, job model.IImageJob, runAt time.Time, err error) error {
+	q.record("retry", true)
+	return nil
+}
+
+func (q *fakeQueue) Fail(ctx context.Context, job model.IImageJob, err error) error {
+	q.record("failed", false)
+	return nil
+}
+
+func TestPool(t *testing.T) {
+	errTemporary := errors.New("temporary")
+	tests := []struct {
+		name     string
+		errs     []error // error del handler en cada intento
+		want     []string
+		maxTries int
+	}{
+		{"success", []error{nil}, []string{"done"}, 3},
+		{"retries then succeeds", []error{errTemporary, nil}, []string{"retry", "done"}, 3},
+		{"gives up after max attempts", []error{errTemporary, errTemporary}, []string{"retry", "failed"}, 2},
+		{"permanent errors are not retried", []error{Permanent(errTemporary)}, []string{"failed"}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queue := newFakeQueue()
+			cfg := config.ImageJobsConfig{Workers: 1, PollInterval: time.Millisecond, MaxAttempts: tt.maxTries, Lease: time.Second}
+			pool := NewPool(cfg, queue, func(ctx context.Context, job model.IImageJob) error {
+				return tt.errs[job.Attempts-1]
+			})
+			pool.Start()
+			defer pool.Stop(context.Background())
+
+			select {
+			case <-queue.done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("job did not finish")
+			}
+			queue.mu.Lock()
+			defer queue.mu.Unlock()
+			if len(queue.outcomes) != len(tt.want) {
+				t.Fatalf("outcomes = %v, want %v", queue.outcomes, tt.want)
+			}
+			for i := range tt.want {
+				if queue.outcomes[i] != tt.want[i] {
+					t.Fatalf("outcomes = %v, want %v", queue.outcomes, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestPoolStopReturnsInterruptedJobsToTheQueue(t *testing.T) {
+	queue := newFakeQueue()
+	cfg := config.ImageJobsConfig{Workers: 1, PollInterval: time.Millisecond, MaxAttempts: 3, Lease: time.Minute}
+	started := make(chan struct{})
+	pool := NewPool(cfg, queue, func(ctx context.Context, job model.IImageJob) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	pool.Start()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := pool.Stop(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Stop = %v, want deadline exceeded", err)
+	}
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+	if len(queue.outcomes) != 1 || queue.outcomes[0] != "retry" {
+		t.Errorf("outcomes = %v, want [retry]", queue.outcomes)
+	}
+}
@@ -11,9 +11,13 @@ type IProducts struct {
 	Title       string             `bson:"title" json:"title"`
 	Price       float64            `bson:"price" json:"price"`
 	Description string             `bson:"description" json:"description"`
-	ImageUrl    string             `bson:"image_url" json:"image_url"`
+	Image       IProductImage      `bson:"image" json:"image"` // copia de la imagen principal de Images
+	Images      []IProductImage    `bson:"images" json:"images"`
+	ImageStatus string             `bson:"image_status" json:"image_status"`
+	Version     int64              `bson:"version" json:"version"` // cambia con cada escritura de los datos o la galería del producto; es el ETag
 	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
 	Rating      float64            `bson:"rating" json:"rating"`
+	ReviewCount int64              `bson:"review_count" json:"review_count"` // reseñas visibles, para ordenar por popularidad
 	Comment     []ICommentData     `bson:"comment" json:"comment"`
 }
 
@@ -21,18 +25,200 @@ type ICreateProduct struct {
 	Title       string  `form:"title" binding:"required"`       // Cambiado a form:"title"
 	Price       float64 `form:"price" binding:"required"`       // Cambiado a form:"price"
 	Description string  `form:"description" binding:"required"` // Cambiado a form:"description"
-	ImageUrl    string  `form:"image_url"`
+	ImageAlt    string  `form:"image_alt" binding:"max=250"`
 }
 
+// IProductPatch son los campos editables de un producto. En PATCH (JSON merge
+// patch) los campos nil no se tocan; en PUT son todos obligatorios.
+type IProductPatch struct {
+	Title       *string  `json:"title" binding:"omitempty,min=1,max=200"`
+	Price       *float64 `json:"price" binding:"omitempty,gt=0"`
+	Description *string  `json:"description" binding:"omitempty,min=1"`
+}
+
+type IReplaceProduct struct {
+	Title       string  `json:"title" binding:"required,max=200"`
+	Price       float64 `json:"price" binding:"required,gt=0"`
+	Description string  `json:"description" binding:"required"`
+}
+
+// Máximo de imágenes en la galería de un producto
+const MaxProductImages = 10
+
+// IProductImage agrupa las versiones subidas de una imagen: Variants de menor a
+// mayor ancho para armar el srcset y Thumbnail con el recorte cuadrado. El orden
+// de la galería es el orden de IProducts.Images.
+type IProductImage struct {
+	Id        primitive.ObjectID `bson:"_id" json:"id"`
+	Alt       string             `bson:"alt" json:"alt"`
+	Primary   bool               `bson:"primary" json:"primary"`
+	Thumbnail *IImageVariant     `bson:"thumbnail,omitempty" json:"thumbnail,omitempty"`
+	Variants  []IImageVariant    `bson:"variants" json:"variants"`
+}
+
+type IImageVariant struct {
+	Name   string `bson:"name" json:"name"`
+	Url    string `bson:"url" json:"url"`
+	Width  int    `bson:"width" json:"width"`
+	Height int    `bson:"height" json:"height"`
+	Key    string `bson:"key,omitempty" json:"-"`
+}
+
+// Keys devuelve las claves en el storage de todas las versiones
+func (image IProductImage) Keys() []string {
+	keys := []string{}
+	if image.Thumbnail != nil && image.Thumbnail.Key != "" {
+		keys = append(keys, image.Thumbnail.Key)
+	}
+	for _, variant := range image.Variants {
+		if variant.Key != "" {
+			keys = append(keys, variant.Key)
+		}
+	}
+	return keys
+}
+
+// Estado de la imagen de un producto nuevo mientras se procesa en segundo plano.
+// Los productos anteriores a la cola no tienen estado y se consideran listos.
+const (
+	ImageStatusPending = "pending"
+	ImageStatusReady   = "ready"
+	ImageStatusFailed  = "failed"
+)
+
+// Estado de un job de la cola de imágenes
+const (
+	ImageJobPending    = "pending"
+	ImageJobProcessing = "processing"
+	ImageJobDone       = "done"
+	ImageJobFailed     = "failed"
+)
+
+// IImageJob es un job de la cola de imágenes: OriginalId es el archivo original
+// guardado en GridFS hasta que el job termina. Error es un mensaje apto para el
+// cliente; el error completo queda en el log.
+type IImageJob struct {
+	Id          primitive.ObjectID `bson:"_id" json:"id"`
+	ProductId   primitive.ObjectID `bson:"product_id" json:"product_id"`
+	OriginalId  primitive.ObjectID `bson:"original_id" json:"-"`
+	Filename    string             `bson:"filename" json:"filename"`
+	Alt         string             `bson:"alt" json:"-"`
+	Status      string             `bson:"status" json:"status"`
+	Attempts    int                `bson:"attempts" json:"attempts"`
+	Error       string             `bson:"error,omitempty" json:"error,omitempty"`
+	RunAt       time.Time          `bson:"run_at" json:"-"`
+	LockedUntil time.Time          `bson:"locked_until" json:"-"`
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt   time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// IImageStatus es la respuesta al consultar el estado de la imagen de un
+// producto; Job es nil para los productos creados antes de la cola
+type IImageStatus struct {
+	ProductId   primitive.ObjectID `json:"product_id"`
+	ImageStatus string             `json:"image_status"`
+	Job         *IImageJob         `json:"job,omitempty"`
+}
+
+type IImageAltForm struct {
+	Alt string `json:"alt" binding:"max=250"`
+}
+
+type IImageOrderForm struct {
+	ImageIds []string `json:"image_ids" binding:"required,min=1"`
+}
+
+// Límites permitidos para la calificación de una reseña
+const (
+	MinCommentRating = 1
+	MaxCommentRating = 5
+)
+
+// Orden disponible al listar productos
+const (
+	ProductSortNewest    = "newest"
+	ProductSortPriceAsc  = "price_asc"
+	ProductSortPriceDesc = "price_desc"
+	ProductSortRating    = "rating"
+	ProductSortTitle     = "title"
+	ProductSortPopular   = "popularity"
+)
+
+// Orden disponible al listar las reseñas de un producto
+const (
+	CommentSortNewest  = "newest"
+	CommentSortHelpful = "helpful"
+	CommentSortRating  = "rating"
+)
+
+// Estado de moderación de una reseña tras pasar por el filtro de contenido
+const (
+	ModerationApproved = "approved"
+	ModerationMasked   = "masked"
+	ModerationPending  = "pending"
+)
+
+// Acciones de moderación sobre una reseña reportada
+const (
+	ReportActionDismiss = "dismiss"
+	ReportActionRemove  = "remove"
+)
+
 type ICommentData struct {
-	Comment   string    `bson:"comment" json:"comment" binding:"required"`
-	Rating    int64     `bson:"rating" json:"rating" binding:"required"`
-	Username  string    `bson:"username" json:"username" binding:"required"`
-	Email     string    `bson:"email" json:"email" binding:"required"`
+	Id             primitive.ObjectID `bson:"_id" json:"id"`
+	UserId         string             `bson:"user_id" json:"user_id"`
+	Comment        string             `bson:"comment" json:"comment" binding:"required"`
+	Rating         int64              `bson:"rating" json:"rating" binding:"required"`
+	Username       string             `bson:"username" json:"username" binding:"required"`
+	Email          string             `bson:"email" json:"email" binding:"required"`
+	HelpfulCount   int64              `bson:"helpful_count" json:"helpful_count"`
+	UnhelpfulCount int64              `bson:"unhelpful_count" json:"unhelpful_count"`
+	Votes          []ICommentVote     `bson:"votes" json:"-"`
+	Reports        []ICommentReport   `bson:"reports" json:"-"`
+	Moderation     ICommentModeration `bson:"moderation" json:"moderation"`
+	CreatedAt      time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt      time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+type ICommentModeration struct {
+	Status    string    `bson:"status" json:"status"`
+	Reasons   []string  `bson:"reasons" json:"reasons"`
+	CheckedAt time.Time `bson:"checked_at" json:"checked_at"`
+}
+
+type ICommentVote struct {
+	UserId    string    `bson:"user_id" json:"user_id"`
+	Helpful   bool      `bson:"helpful" json:"helpful"`
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+}
+
+type ICommentReport struct {
+	UserId    string    `bson:"user_id" json:"user_id"`
+	Username  string    `bson:"username" json:"username"`
+	Reason    string    `bson:"reason" json:"reason"`
 	CreatedAt time.Time `bson:"created_at" json:"created_at"`
 }
 
+type IReportedComment struct {
+	ProductId    primitive.ObjectID `bson:"product_id" json:"product_id"`
+	ProductTitle string             `bson:"product_title" json:"product_title"`
+	Comment      ICommentData       `bson:"comment" json:"comment"`
+	Reports      []ICommentReport   `bson:"reports" json:"reports"`
+}
+
 type IComment struct {
 	Comment string `form:"comment" binding:"required"`
-	Rating  int64  `form:"rating" binding:"required"`
+	Rating  int64  `form:"rating" binding:"required,min=1,max=5"`
+}
+
+type ICommentVoteForm struct {
+	Helpful *bool `form:"helpful" json:"helpful" binding:"required"`
+}
+
+type ICommentReportForm struct {
+	Reason string `form:"reason" json:"reason" binding:"required,max=500"`
+}
+
+type IResolveReportForm struct {
+	Action string `form:"action" json:"action" binding:"required,oneof=dismiss remove"`
 }
@@ -0,0 +1,158 @@
+package moderation
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxLengthFilter rechaza textos con más caracteres de los permitidos
+type MaxLengthFilter struct {
+	Max int
+}
+
+func (f MaxLengthFilter) Check(text string) Result {
+	if utf8.RuneCountInString(text) > f.Max {
+		return Result{Action: ActionReject, Text: text, Reasons: []string{fmt.Sprintf("max_length: más de %d caracteres", f.Max)}}
+	}
+	return Result{Action: ActionAllow, Text: text}
+}
+
+// BannedWordsFilter busca palabras prohibidas sin distinguir mayúsculas
+type BannedWordsFilter struct {
+	words  map[string]bool
+	action Action
+}
+
+func NewBannedWordsFilter(words []string, action Action) BannedWordsFilter {
+	set := map[string]bool{}
+	for _, word := range words {
+		word = strings.ToLower(strings.TrimSpace(word))
+		if word != "" {
+			set[word] = true
+		}
+	}
+	return BannedWordsFilter{words: set, action: action}
+}
+
+func (f BannedWordsFilter) Check(text string) Result {
+	runes := []rune(text)
+	found := false
+	for start := 0; start < len(runes); {
+		if !isWordRune(runes[start]) {
+			start++
+			continue
+		}
+		end := start
+		for end < len(runes) && isWordRune(runes[end]) {
+			end++
+		}
+		if f.words[strings.ToLower(string(runes[start:end]))] {
+			found = true
+			if f.action == ActionMask {
+				for i := start; i < end; i++ {
+					runes[i] = '*'
+				}
+			}
+		}
+		start = end
+	}
+	if !found {
+		return Result{Action: ActionAllow, Text: text}
+	}
+	return Result{Action: f.action, Text: string(runes), Reasons: []string{"banned_words"}}
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+var linkPattern = regexp.MustCompile(`(?i)(https?://|www\.)\S+|\b[a-z0-9-]+\.(com|net|org|io|co|info|biz|xyz|ru|es|mx|ly)\b`)
+
+// LinkFilter detecta enlaces, la forma más común de spam en reseñas. Con
+// ActionMask cada enlace se reemplaza por asteriscos.
+type LinkFilter struct {
+	Action Action
+}
+
+func (f LinkFilter) Check(text string) Result {
+	if f.Action == ActionAllow || !linkPattern.MatchString(text) {
+		return Result{Action: ActionAllow, Text: text}
+	}
+	if f.Action == ActionMask {
+		text = linkPattern.ReplaceAllStringFunc(text, func(link string) string {
+			return strings.Repeat("*", utf8.RuneCountInString(link))
+		})
+	}
+	return Result{Action: f.Action, Text: text, Reasons: []string{"links"}}
+}
+
+// ShoutingFilter detecta caracteres repetidos ("buenooooooo!!!!!!") y textos en
+// mayúsculas. Con ActionMask las repeticiones se recortan a MaxRepeatedChars y
+// un texto en mayúsculas se pasa a minúsculas.
+type ShoutingFilter struct {
+	Action            Action
+	MaxRepeatedChars  int
+	MaxUppercaseRatio float64
+	MinLetters        int
+}
+
+func (f ShoutingFilter) Check(text string) Result {
+	if f.Action == ActionAllow {
+		return Result{Action: ActionAllow, Text: text}
+	}
+	reasons := []string{}
+
+	var prev rune
+	repeated, letters, upper := 0, 0, 0
+	trimmed := []rune{}
+	for _, r := range text {
+		if r == prev && !unicode.IsSpace(r) {
+			repeated++
+		} else {
+			repeated = 1
+		}
+		prev = r
+		if f.MaxRepeatedChars > 0 && repeated > f.MaxRepeatedChars {
+			if repeated == f.MaxRepeatedChars+1 {
+				reasons = append(reasons, "repeated_characters")
+			}
+		} else {
+			trimmed = append(trimmed, r)
+		}
+		if unicode.IsLetter(r) {
+			letters++
+			if unicode.IsUpper(r) {
+				upper++
+			}
+		}
+	}
+	shouting := f.MaxUppercaseRatio > 0 && letters >= f.MinLetters && float64(upper)/float64(letters) > f.MaxUppercaseRatio
+	if shouting {
+		reasons = append(reasons, "shouting")
+	}
+	if len(reasons) == 0 {
+		return Result{Action: ActionAllow, Text: text}
+	}
+	if f.Action == ActionMask {
+		text = string(trimmed)
+		if shouting {
+			text = strings.ToLower(text)
+		}
+	}
+	return Result{Action: f.Action, Text: text, Reasons: dedupe(reasons)}
+}
+
+func dedupe(values []string) []string {
+	seen := map[string]bool{}
+	out := []string{}
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	return out
+}
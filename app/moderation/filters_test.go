@@ -0,0 +1,90 @@
+package moderation
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFilters(t *testing.T) {
+	shouting := func(action Action) ShoutingFilter {
+		return ShoutingFilter{Action: action, MaxRepeatedChars: 3, MaxUppercaseRatio: 0.7, MinLetters: 10}
+	}
+	tests := []struct {
+		name       string
+		filter     Filter
+		text       string
+		wantAction Action
+		wantText   string
+		wantReason []string
+	}{
+		{"max length allows short text", MaxLengthFilter{Max: 5}, "hola", ActionAllow, "hola", nil},
+		{"max length counts runes", MaxLengthFilter{Max: 4}, "ñandú", ActionReject, "ñandú", []string{"max_length: más de 4 caracteres"}},
+
+		{"banned words allow clean text", NewBannedWordsFilter([]string{"feo"}, ActionMask), "muy lindo", ActionAllow, "muy lindo", nil},
+		{"banned words mask", NewBannedWordsFilter([]string{" Feo "}, ActionMask), "Es FEO, feísimo", ActionMask, "Es ***, feísimo", []string{"banned_words"}},
+		{"banned words hold", NewBannedWordsFilter([]string{"feo"}, ActionHold), "es feo", ActionHold, "es feo", []string{"banned_words"}},
+		{"banned words reject", NewBannedWordsFilter([]string{"feo"}, ActionReject), "es feo", ActionReject, "es feo", []string{"banned_words"}},
+
+		{"links with allow action", LinkFilter{Action: ActionAllow}, "ver https://spam.example", ActionAllow, "ver https://spam.example", nil},
+		{"links clean text", LinkFilter{Action: ActionHold}, "sin enlaces.", ActionAllow, "sin enlaces.", nil},
+		{"links mask", LinkFilter{Action: ActionMask}, "ver https://spam.io/x y www.otro.net", ActionMask, "ver ***************** y ************", []string{"links"}},
+		{"links mask bare domain", LinkFilter{Action: ActionMask}, "compra en oferta.com ya", ActionMask, "compra en ********** ya", []string{"links"}},
+		{"links hold", LinkFilter{Action: ActionHold}, "ver oferta.com", ActionHold, "ver oferta.com", []string{"links"}},
+		{"links reject", LinkFilter{Action: ActionReject}, "ver oferta.com", ActionReject, "ver oferta.com", []string{"links"}},
+
+		{"shouting with allow action", shouting(ActionAllow), "BUENOOOOOO PRODUCTO", ActionAllow, "BUENOOOOOO PRODUCTO", nil},
+		{"shouting clean text", shouting(ActionHold), "Buen producto, llegó rápido", ActionAllow, "Buen producto, llegó rápido", nil},
+		{"shouting mask repeated characters", shouting(ActionMask), "buenooooo!!!!!", ActionMask, "buenooo!!!", []string{"repeated_characters"}},
+		{"shouting mask uppercase", shouting(ActionMask), "EXCELENTE PRODUCTO", ActionMask, "excelente producto", []string{"shouting"}},
+		{"shouting mask both", shouting(ActionMask), "MALÍSIMOOOOO PRODUCTO", ActionMask, "malísimooo producto", []string{"repeated_characters", "shouting"}},
+		{"shouting short uppercase text", shouting(ActionHold), "OK BIEN", ActionAllow, "OK BIEN", nil},
+		{"shouting hold", shouting(ActionHold), "EXCELENTE PRODUCTO", ActionHold, "EXCELENTE PRODUCTO", []string{"shouting"}},
+		{"shouting reject", shouting(ActionReject), "buenooooo", ActionReject, "buenooooo", []string{"repeated_characters"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.filter.Check(tt.text)
+			if got.Action != tt.wantAction {
+				t.Errorf("action = %s, want %s", got.Action, tt.wantAction)
+			}
+			if got.Text != tt.wantText {
+				t.Errorf("text = %q, want %q", got.Text, tt.wantText)
+			}
+			if !reflect.DeepEqual(got.Reasons, tt.wantReason) {
+				t.Errorf("reasons = %v, want %v", got.Reasons, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestPipelineKeepsMostSevereActionAndMaskedText(t *testing.T) {
+	p := NewPipeline(
+		NewBannedWordsFilter([]string{"feo"}, ActionMask),
+		LinkFilter{Action: ActionMask},
+		LinkFilter{Action: ActionHold},
+	)
+	got := p.Run("feo, ver oferta.com")
+	if got.Action != ActionMask {
+		t.Errorf("action = %s, want mask: the link was already masked", got.Action)
+	}
+	if got.Text != "***, ver **********" {
+		t.Errorf("text = %q", got.Text)
+	}
+
+	got = NewPipeline(NewBannedWordsFilter([]string{"feo"}, ActionMask), MaxLengthFilter{Max: 3}).Run("feo feo")
+	if got.Action != ActionReject || got.Text != "*** ***" {
+		t.Errorf("got %s %q, want reject with the masked text", got.Action, got.Text)
+	}
+}
+
+func TestParseAction(t *testing.T) {
+	for _, name := range []string{"allow", "mask", "hold", "reject"} {
+		action, err := ParseAction(" " + name + " ")
+		if err != nil || action.String() != name {
+			t.Errorf("ParseAction(%q) = %s, %v", name, action, err)
+		}
+	}
+	if _, err := ParseAction("ban"); err == nil {
+		t.Error("expected an error for an unknown action")
+	}
+}
@@ -0,0 +1,137 @@
+package moderation
+
+import (
+	"fmt"
+	"mgo-gin/config"
+	"strings"
+)
+
+// Action es la decisión de un filtro sobre un texto, ordenada de menor a mayor severidad
+type Action int
+
+const (
+	ActionAllow Action = iota
+	ActionMask
+	ActionHold
+	ActionReject
+)
+
+func (a Action) String() string {
+	switch a {
+	case ActionMask:
+		return "mask"
+	case ActionHold:
+		return "hold"
+	case ActionReject:
+		return "reject"
+	default:
+		return "allow"
+	}
+}
+
+// ParseAction convierte el nombre de una acción ("allow", "mask", "hold", "reject")
+func ParseAction(name string) (Action, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "allow":
+		return ActionAllow, nil
+	case "mask":
+		return ActionMask, nil
+	case "hold":
+		return ActionHold, nil
+	case "reject":
+		return ActionReject, nil
+	}
+	return ActionAllow, fmt.Errorf("acción de moderación desconocida: %q", name)
+}
+
+// Result es el resultado de pasar un texto por uno o varios filtros
+type Result struct {
+	Action  Action
+	Text    string
+	Reasons []string
+}
+
+// Filter revisa un texto y decide qué hacer con él
+type Filter interface {
+	Check(text string) Result
+}
+
+// Pipeline ejecuta los filtros en orden: el texto enmascarado por un filtro pasa
+// al siguiente y la decisión final es la más severa de todas.
+type Pipeline struct {
+	filters []Filter
+}
+
+func NewPipeline(filters ...Filter) *Pipeline {
+	return &Pipeline{filters: filters}
+}
+
+func (p *Pipeline) Run(text string) Result {
+	result := Result{Action: ActionAllow, Text: text}
+	if p == nil {
+		return result
+	}
+	for _, filter := range p.filters {
+		r := filter.Check(result.Text)
+		if r.Action > result.Action {
+			result.Action = r.Action
+		}
+		if r.Action == ActionMask {
+			result.Text = r.Text
+		}
+		result.Reasons = append(result.Reasons, r.Reasons...)
+	}
+	return result
+}
+
+type Config struct {
+	BannedWords       []string
+	BannedWordsAction Action
+	LinkAction        Action
+	ShoutingAction    Action
+	MaxLength         int
+	MaxRepeatedChars  int
+	MaxUppercaseRatio float64
+}
+
+func DefaultConfig() Config {
+	return Config{
+		BannedWordsAction: ActionMask,
+		LinkAction:        ActionHold,
+		ShoutingAction:    ActionHold,
+		MaxLength:         2000,
+		MaxRepeatedChars:  5,
+		MaxUppercaseRatio: 0.7,
+	}
+}
+
+// ConfigFromReviews traduce la configuración de reseñas al filtro de contenido.
+// config.Load ya validó los nombres de las acciones.
+func ConfigFromReviews(cfg config.ReviewsConfig) Config {
+	out := DefaultConfig()
+	out.BannedWords = cfg.BannedWords
+	out.BannedWordsAction, _ = ParseAction(cfg.BannedWordsAction)
+	out.LinkAction, _ = ParseAction(cfg.LinkAction)
+	out.ShoutingAction, _ = ParseAction(cfg.ShoutingAction)
+	out.MaxLength = cfg.MaxLength
+	return out
+}
+
+// NewPipelineFromConfig arma el pipeline de reseñas a partir de la configuración
+func NewPipelineFromConfig(cfg Config) *Pipeline {
+	filters := []Filter{}
+	if cfg.MaxLength > 0 {
+		filters = append(filters, MaxLengthFilter{Max: cfg.MaxLength})
+	}
+	if len(cfg.BannedWords) > 0 {
+		filters = append(filters, NewBannedWordsFilter(cfg.BannedWords, cfg.BannedWordsAction))
+	}
+	filters = append(filters, LinkFilter{Action: cfg.LinkAction})
+	filters = append(filters, ShoutingFilter{
+		Action:            cfg.ShoutingAction,
+		MaxRepeatedChars:  cfg.MaxRepeatedChars,
+		MaxUppercaseRatio: cfg.MaxUppercaseRatio,
+		MinLetters:        10,
+	})
+	return NewPipeline(filters...)
+}
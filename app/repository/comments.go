@@ -0,0 +1,394 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"mgo-gin/app/model"
+	"mgo-gin/utils/logger"
+	"net/http"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MigrateCommentIds asigna un _id a las reseñas guardadas antes de que existiera,
+// necesario para poder votarlas o reportarlas. Los ids se generan acá pero la
+// escritura es un pipeline que solo completa las reseñas que siguen sin _id, así
+// no pisa votos, reportes ni reseñas que lleguen mientras corre.
+func (entity *productEntity) MigrateCommentIds(ctx context.Context) error {
+	filter := bson.M{"comment": bson.M{"$elemMatch": bson.M{"_id": bson.M{"$exists": false}}}}
+	cursor, err := entity.repo.Find(ctx, filter, options.Find().SetProjection(bson.M{"comment._id": 1}))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var product model.IProducts
+		if err := cursor.Decode(&product); err != nil {
+			logger.FromContext(ctx).WithError(err).Error("Error decoding product")
+			continue
+		}
+		_, err = entity.repo.UpdateOne(ctx, bson.M{"_id": product.Id, "comment": filter["comment"]}, assignCommentIds(len(product.Comment)))
+		if err != nil {
+			logger.FromContext(ctx).WithError(err).Error("Error updating product comments")
+		}
+	}
+	return cursor.Err()
+}
+
+// assignCommentIds arma el pipeline que da a la reseña en la posición i el id
+// nuevo i si todavía no tiene uno. Las reseñas agregadas después de la lectura
+// ya tienen _id y quedan como están.
+func assignCommentIds(count int) []bson.M {
+	ids := bson.A{}
+	for i := 0; i < count; i++ {
+		ids = append(ids, primitive.NewObjectID())
+	}
+	comments := bson.M{"$ifNull": bson.A{"$comment", bson.A{}}}
+	return []bson.M{{"$set": bson.M{
+		"comment": bson.M{"$map": bson.M{
+			"input": bson.M{"$range": bson.A{0, bson.M{"$size": comments}}},
+			"as":    "i",
+			"in": bson.M{"$let": bson.M{
+				"vars": bson.M{"c": bson.M{"$arrayElemAt": bson.A{comments, "$$i"}}},
+				"in": bson.M{"$cond": bson.A{
+					bson.M{"$eq": bson.A{bson.M{"$type": "$$c._id"}, "missing"}},
+					bson.M{"$mergeObjects": bson.A{"$$c", bson.M{"_id": bson.M{"$arrayElemAt": bson.A{ids, "$$i"}}}}},
+					"$$c",
+				}},
+			}},
+		}},
+	}}}
+}
+
+// MigrateReviewCounts calcula review_count en los productos anteriores al orden
+// por popularidad
+func (entity *productEntity) MigrateReviewCounts(ctx context.Context) error {
+	filter := bson.M{"review_count": bson.M{"$exists": false}}
+	cursor, err := entity.repo.Find(ctx, filter, options.Find().SetProjection(bson.M{"comment": 1}))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var product model.IProducts
+		if err := cursor.Decode(&product); err != nil {
+			logger.FromContext(ctx).WithError(err).Error("Error decoding product")
+			continue
+		}
+		_, err = entity.repo.UpdateOne(ctx, bson.M{"_id": product.Id}, bson.M{"$set": bson.M{"review_count": reviewCount(product.Comment)}})
+		if err != nil {
+			logger.FromContext(ctx).WithError(err).Error("Error updating product review count")
+		}
+	}
+	return cursor.Err()
+}
+
+func (entity *productEntity) GetComments(ctx context.Context, productid string, sortBy string) ([]model.ICommentData, int, error) {
+	product, statusCode, err := entity.GetOneProduct(ctx, productid)
+	if err != nil {
+		return []model.ICommentData{}, statusCode, err
+	}
+	comments := product.Comment
+	if comments == nil {
+		comments = []model.ICommentData{}
+	}
+	sortComments(comments, sortBy)
+	return comments, http.StatusOK, nil
+}
+
+// VoteComment registra el voto del usuario en una sola escritura: si ya había
+// votado se reemplaza su voto y los contadores se recalculan con la lista nueva
+func (entity *productEntity) VoteComment(ctx context.Context, productid string, commentid string, userId string, helpful bool) (model.ICommentData, int, error) {
+	ctx, cancel := initContext(ctx, entity.resource.QueryTimeout)
+	defer cancel()
+
+	objID, commentObjID, err := parseCommentIDs(productid, commentid)
+	if err != nil {
+		return model.ICommentData{}, getHTTPCode(err), err
+	}
+
+	vote := model.ICommentVote{UserId: userId, Helpful: helpful, CreatedAt: time.Now().UTC()}
+	votes := bson.M{"$concatArrays": bson.A{
+		bson.M{"$filter": bson.M{
+			"input": bson.M{"$ifNull": bson.A{"$$c.votes", bson.A{}}},
+			"cond":  bson.M{"$ne": bson.A{"$$this.user_id", userId}},
+		}},
+		bson.A{bson.M{"$literal": vote}},
+	}}
+	fields := bson.M{"$let": bson.M{
+		"vars": bson.M{"votes": votes},
+		"in": bson.M{
+			"votes":           "$$votes",
+			"helpful_count":   bson.M{"$size": bson.M{"$filter": bson.M{"input": "$$votes", "cond": "$$this.helpful"}}},
+			"unhelpful_count": bson.M{"$size": bson.M{"$filter": bson.M{"input": "$$votes", "cond": bson.M{"$not": bson.A{"$$this.helpful"}}}}},
+		},
+	}}
+	filter := bson.M{"_id": objID, "comment": bson.M{"$elemMatch": bson.M{"_id": commentObjID, "user_id": bson.M{"$ne": userId}}}}
+	product, err := entity.updateComments(ctx, filter, setCommentFields(isComment(commentObjID), fields))
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		// Nada coincidió: la reseña no existe o es del propio usuario
+		if _, statusCode, findErr := entity.findComment(ctx, productid, commentid); findErr != nil {
+			return model.ICommentData{}, statusCode, findErr
+		}
+		return model.ICommentData{}, http.StatusBadRequest, errors.New("no puedes votar tu propia reseña")
+	}
+	if err != nil {
+		logger.FromContext(ctx).WithError(err).Error("Error updating product")
+		return model.ICommentData{}, getHTTPCode(err), err
+	}
+	return commentByID(product, commentObjID), http.StatusOK, nil
+}
+
+// ReportComment agrega el reporte solo si el usuario no reportó antes la reseña;
+// el filtro hace que dos reportes simultáneos no se pisen ni se dupliquen
+func (entity *productEntity) ReportComment(ctx context.Context, productid string, commentid string, userId string, username string, reason string) (model.ICommentData, int, error) {
+	ctx, cancel := initContext(ctx, entity.resource.QueryTimeout)
+	defer cancel()
+
+	objID, commentObjID, err := parseCommentIDs(productid, commentid)
+	if err != nil {
+		return model.ICommentData{}, getHTTPCode(err), err
+	}
+
+	report := model.ICommentReport{
+		UserId:    userId,
+		Username:  username,
+		Reason:    reason,
+		CreatedAt: time.Now().UTC(),
+	}
+	fields := bson.M{"reports": bson.M{"$concatArrays": bson.A{
+		bson.M{"$ifNull": bson.A{"$$c.reports", bson.A{}}},
+		bson.A{bson.M{"$literal": report}},
+	}}}
+	filter := bson.M{"_id": objID, "comment": bson.M{"$elemMatch": bson.M{"_id": commentObjID, "reports.user_id": bson.M{"$ne": userId}}}}
+	product, err := entity.updateComments(ctx, filter, setCommentFields(isComment(commentObjID), fields))
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		if _, statusCode, findErr := entity.findComment(ctx, productid, commentid); findErr != nil {
+			return model.ICommentData{}, statusCode, findErr
+		}
+		return model.ICommentData{}, http.StatusConflict, errors.New("ya reportaste esta reseña")
+	}
+	if err != nil {
+		logger.FromContext(ctx).WithError(err).Error("Error updating product")
+		return model.ICommentData{}, getHTTPCode(err), err
+	}
+	return commentByID(product, commentObjID), http.StatusCreated, nil
+}
+
+// GetReportedComments devuelve la cola de moderación: reseñas reportadas o retenidas
+// por el filtro de contenido, las que tienen más reportes primero
+func (entity *productEntity) GetReportedComments(ctx context.Context) ([]model.IReportedComment, int, error) {
+	ctx, cancel := initContext(ctx, entity.resource.QueryTimeout)
+	defer cancel()
+
+	needsReview := bson.M{"$or": []bson.M{
+		{"comment.reports.0": bson.M{"$exists": true}},
+		{"comment.moderation.status": model.ModerationPending},
+	}}
+	pipeline := []bson.M{
+		{"$match": needsReview},
+		{"$unwind": "$comment"},
+		{"$match": needsReview},
+		{"$project": bson.M{
+			"_id":           0,
+			"product_id":    "$_id",
+			"product_title": "$title",
+			"comment":       "$comment",
+			"reports":       "$comment.reports",
+			"report_count":  bson.M{"$size": bson.M{"$ifNull": bson.A{"$comment.reports", bson.A{}}}},
+		}},
+		{"$sort": bson.D{{Key: "report_count", Value: -1}, {Key: "comment.created_at", Value: 1}}},
+	}
+	cursor, err := entity.repo.Aggregate(ctx, pipeline, options.Aggregate())
+	if err != nil {
+		logger.FromContext(ctx).WithError(err).Error("Error aggregating reported comments")
+		return []model.IReportedComment{}, getHTTPCode(err), err
+	}
+	defer cursor.Close(ctx)
+
+	reported := []model.IReportedComment{}
+	if err = cursor.All(ctx, &reported); err != nil {
+		logger.FromContext(ctx).WithError(err).Error("Error decoding reported comments")
+		return []model.IReportedComment{}, getHTTPCode(err), err
+	}
+	return reported, http.StatusOK, nil
+}
+
+// ResolveReportedComment descarta los reportes de una reseña o la elimina del
+// producto. La reseña, la calificación y review_count cambian en la misma escritura.
+func (entity *productEntity) ResolveReportedComment(ctx context.Context, productid string, commentid string, action string) (model.IProducts, int, error) {
+	ctx, cancel := initContext(ctx, entity.resource.QueryTimeout)
+	defer cancel()
+
+	objID, commentObjID, err := parseCommentIDs(productid, commentid)
+	if err != nil {
+		return model.IProducts{}, getHTTPCode(err), err
+	}
+
+	var update bson.M
+	switch action {
+	case model.ReportActionDismiss:
+		// Descartar también aprueba una reseña retenida por el filtro de contenido
+		update = setCommentFields(isComment(commentObjID), bson.M{
+			"reports": bson.A{},
+			"moderation": bson.M{"$cond": bson.A{
+				bson.M{"$eq": bson.A{"$$c.moderation.status", model.ModerationPending}},
+				bson.M{"$mergeObjects": bson.A{"$$c.moderation", bson.M{
+					"status":     model.ModerationApproved,
+					"checked_at": bson.M{"$literal": time.Now().UTC()},
+				}}},
+				"$$c.moderation",
+			}},
+		})
+	case model.ReportActionRemove:
+		update = bson.M{"$set": bson.M{"comment": bson.M{"$filter": bson.M{
+			"input": bson.M{"$ifNull": bson.A{"$comment", bson.A{}}},
+			"cond":  bson.M{"$ne": bson.A{"$$this._id", commentObjID}},
+		}}}}
+	default:
+		return model.IProducts{}, http.StatusBadRequest, errors.New("acción de moderación inválida")
+	}
+
+	filter := bson.M{"_id": objID, "comment._id": commentObjID}
+	product, err := entity.updateComments(ctx, filter, update)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		// Nada coincidió: falta el producto o la reseña
+		_, statusCode, findErr := entity.findComment(ctx, productid, commentid)
+		if findErr == nil {
+			statusCode, findErr = http.StatusNotFound, errReviewNotFound
+		}
+		return model.IProducts{}, statusCode, findErr
+	}
+	if err != nil {
+		logger.FromContext(ctx).WithError(err).Error("Error updating product")
+		return model.IProducts{}, getHTTPCode(err), err
+	}
+	return product, http.StatusOK, nil
+}
+
+// updateComments aplica update (una etapa de update pipeline) y recalcula la
+// calificación en el mismo comando. Devuelve el producto ya actualizado o
+// mongo.ErrNoDocuments si filter no coincidió.
+func (entity *productEntity) updateComments(ctx context.Context, filter bson.M, update bson.M) (model.IProducts, error) {
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var product model.IProducts
+	err := entity.repo.FindOneAndUpdate(ctx, filter, []bson.M{update, ratingStage()}, opts).Decode(&product)
+	return product, err
+}
+
+// setCommentFields arma la etapa que mezcla fields en las reseñas que cumplen
+// match y deja el resto como está; dentro de match y fields la reseña es $$c.
+// Los valores que vienen del usuario van con $literal para que un texto que
+// empiece con $ no se lea como expresión.
+func setCommentFields(match interface{}, fields interface{}) bson.M {
+	return bson.M{"$set": bson.M{"comment": bson.M{"$map": bson.M{
+		"input": bson.M{"$ifNull": bson.A{"$comment", bson.A{}}},
+		"as":    "c",
+		"in": bson.M{"$cond": bson.A{
+			match,
+			bson.M{"$mergeObjects": bson.A{"$$c", fields}},
+			"$$c",
+		}},
+	}}}}
+}
+
+func isComment(id primitive.ObjectID) bson.M {
+	return bson.M{"$eq": bson.A{"$$c._id", id}}
+}
+
+// ratingStage recalcula rating y review_count con las reseñas visibles, igual
+// que averageRating y reviewCount
+func ratingStage() bson.M {
+	visible := bson.M{"$filter": bson.M{
+		"input": bson.M{"$ifNull": bson.A{"$comment", bson.A{}}},
+		"cond":  bson.M{"$ne": bson.A{"$$this.moderation.status", model.ModerationPending}},
+	}}
+	return bson.M{"$set": bson.M{
+		"review_count": bson.M{"$size": visible},
+		"rating": bson.M{"$let": bson.M{
+			"vars": bson.M{"ratings": bson.M{"$map": bson.M{"input": visible, "in": "$$this.rating"}}},
+			"in": bson.M{"$cond": bson.A{
+				bson.M{"$eq": bson.A{bson.M{"$size": "$$ratings"}, 0}},
+				1.0,
+				// Redondeo a un decimal como math.Round
+				bson.M{"$divide": bson.A{bson.M{"$floor": bson.M{"$add": bson.A{bson.M{"$multiply": bson.A{bson.M{"$avg": "$$ratings"}, 10}}, 0.5}}}, 10}},
+			}},
+		}},
+	}}
+}
+
+func parseCommentIDs(productid string, commentid string) (primitive.ObjectID, primitive.ObjectID, error) {
+	objID, err := parseObjectID(productid)
+	if err != nil {
+		return primitive.NilObjectID, primitive.NilObjectID, err
+	}
+	commentObjID, err := parseObjectID(commentid)
+	if err != nil {
+		return primitive.NilObjectID, primitive.NilObjectID, err
+	}
+	return objID, commentObjID, nil
+}
+
+func commentByID(product model.IProducts, id primitive.ObjectID) model.ICommentData {
+	for _, comment := range product.Comment {
+		if comment.Id == id {
+			return comment
+		}
+	}
+	return model.ICommentData{}
+}
+
+var errReviewNotFound = errors.New("reseña no encontrada")
+
+// findComment lee la reseña; sirve para explicar por qué un update no coincidió
+func (entity *productEntity) findComment(ctx context.Context, productid string, commentid string) (model.ICommentData, int, error) {
+	commentObjID, err := parseObjectID(commentid)
+	if err != nil {
+		return model.ICommentData{}, getHTTPCode(err), err
+	}
+	product, statusCode, err := entity.findProduct(ctx, productid)
+	if err != nil {
+		return model.ICommentData{}, statusCode, err
+	}
+	for _, comment := range product.Comment {
+		if comment.Id == commentObjID {
+			return comment, http.StatusOK, nil
+		}
+	}
+	return model.ICommentData{}, http.StatusNotFound, errReviewNotFound
+}
+
+func sortComments(comments []model.ICommentData, sortBy string) {
+	switch sortBy {
+	case model.CommentSortHelpful:
+		sort.SliceStable(comments, func(i, j int) bool {
+			si := comments[i].HelpfulCount - comments[i].UnhelpfulCount
+			sj := comments[j].HelpfulCount - comments[j].UnhelpfulCount
+			if si != sj {
+				return si > sj
+			}
+			if comments[i].HelpfulCount != comments[j].HelpfulCount {
+				return comments[i].HelpfulCount > comments[j].HelpfulCount
+			}
+			return comments[i].CreatedAt.After(comments[j].CreatedAt)
+		})
+	case model.CommentSortRating:
+		sort.SliceStable(comments, func(i, j int) bool {
+			if comments[i].Rating != comments[j].Rating {
+				return comments[i].Rating > comments[j].Rating
+			}
+			return comments[i].CreatedAt.After(comments[j].CreatedAt)
+		})
+	default:
+		sort.SliceStable(comments, func(i, j int) bool {
+			return comments[i].CreatedAt.After(comments[j].CreatedAt)
+		})
+	}
+}
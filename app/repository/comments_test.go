@@ -0,0 +1,142 @@
+package repository
+
+import (
+	"context"
+	"mgo-gin/app/model"
+	"net/http"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// addTestReview agrega la reseña de userId y devuelve su id
+func addTestReview(t *testing.T, entity *productEntity, productid string, userId string, rating int64) string {
+	t.Helper()
+	product, _, err := entity.AddComment(context.Background(), productid, userId, userId, userId+"@example.com", model.IComment{Comment: "reseña", Rating: rating})
+	if err != nil {
+		t.Fatalf("AddComment: %v", err)
+	}
+	for _, comment := range product.Comment {
+		if comment.UserId == userId {
+			return comment.Id.Hex()
+		}
+	}
+	t.Fatalf("review from %s not found", userId)
+	return ""
+}
+
+func TestVoteComment(t *testing.T) {
+	entity := newTestEntity(t)
+	productid := insertTestProduct(t, entity)
+	commentid := addTestReview(t, entity, productid, "autor", 5)
+	ctx := context.Background()
+
+	if _, statusCode, err := entity.VoteComment(ctx, productid, commentid, "autor", true); statusCode != http.StatusBadRequest {
+		t.Errorf("voting your own review: %d %v, want 400", statusCode, err)
+	}
+	if _, statusCode, err := entity.VoteComment(ctx, productid, primitive.NewObjectID().Hex(), "u1", true); statusCode != http.StatusNotFound {
+		t.Errorf("voting a missing review: %d %v, want 404", statusCode, err)
+	}
+
+	if _, _, err := entity.VoteComment(ctx, productid, commentid, "u1", true); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := entity.VoteComment(ctx, productid, commentid, "u2", true); err != nil {
+		t.Fatal(err)
+	}
+	// Volver a votar reemplaza el voto anterior del usuario
+	comment, statusCode, err := entity.VoteComment(ctx, productid, commentid, "u1", false)
+	if err != nil || statusCode != http.StatusOK {
+		t.Fatalf("changing the vote: %d %v", statusCode, err)
+	}
+	if len(comment.Votes) != 2 || comment.HelpfulCount != 1 || comment.UnhelpfulCount != 1 {
+		t.Errorf("got %d votes, %d helpful, %d unhelpful; want 2, 1, 1", len(comment.Votes), comment.HelpfulCount, comment.UnhelpfulCount)
+	}
+}
+
+func TestReportAndResolveComment(t *testing.T) {
+	entity := newTestEntity(t)
+	productid := insertTestProduct(t, entity)
+	reported := addTestReview(t, entity, productid, "u1", 1)
+	addTestReview(t, entity, productid, "u2", 5)
+	ctx := context.Background()
+
+	comment, statusCode, err := entity.ReportComment(ctx, productid, reported, "u3", "carla", "spam")
+	if err != nil || statusCode != http.StatusCreated || len(comment.Reports) != 1 {
+		t.Fatalf("first report: %d %v %+v", statusCode, err, comment.Reports)
+	}
+	if _, statusCode, _ := entity.ReportComment(ctx, productid, reported, "u3", "carla", "spam"); statusCode != http.StatusConflict {
+		t.Errorf("reporting twice: %d, want 409", statusCode)
+	}
+	if comment, _, err := entity.ReportComment(ctx, productid, reported, "u4", "dani", "ofensiva"); err != nil || len(comment.Reports) != 2 {
+		t.Errorf("report from another user: %v %+v", err, comment.Reports)
+	}
+
+	if _, statusCode, _ := entity.ResolveReportedComment(ctx, productid, reported, "ban"); statusCode != http.StatusBadRequest {
+		t.Errorf("unknown action: %d, want 400", statusCode)
+	}
+	product, _, err := entity.ResolveReportedComment(ctx, productid, reported, model.ReportActionDismiss)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := commentByID(product, mustObjectID(t, reported)); len(c.Reports) != 0 {
+		t.Errorf("dismiss left %d reports", len(c.Reports))
+	}
+
+	product, _, err = entity.ResolveReportedComment(ctx, productid, reported, model.ReportActionRemove)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(product.Comment) != 1 || product.ReviewCount != 1 || product.Rating != 5 {
+		t.Errorf("after remove: %d reviews, review_count %d, rating %v; want 1, 1, 5", len(product.Comment), product.ReviewCount, product.Rating)
+	}
+	if _, statusCode, _ := entity.ResolveReportedComment(ctx, productid, reported, model.ReportActionRemove); statusCode != http.StatusNotFound {
+		t.Errorf("removing twice: %d, want 404", statusCode)
+	}
+}
+
+func TestMigrateCommentIds(t *testing.T) {
+	entity := newTestEntity(t)
+	ctx := context.Background()
+	existing := primitive.NewObjectID()
+	productId := primitive.NewObjectID()
+	_, err := entity.repo.InsertOne(ctx, bson.M{"_id": productId, "comment": bson.A{
+		bson.M{"comment": "antigua", "rating": 3, "helpful_count": 2},
+		bson.M{"_id": existing, "comment": "nueva", "rating": 4},
+		bson.M{"comment": "otra antigua", "rating": 5},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := entity.MigrateCommentIds(ctx); err != nil {
+		t.Fatal(err)
+	}
+	product, _, err := entity.findProduct(ctx, productId.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := map[primitive.ObjectID]bool{}
+	for _, comment := range product.Comment {
+		if comment.Id.IsZero() || seen[comment.Id] {
+			t.Errorf("review %q has id %s", comment.Comment, comment.Id.Hex())
+		}
+		seen[comment.Id] = true
+	}
+	if product.Comment[1].Id != existing {
+		t.Errorf("an existing id was replaced")
+	}
+	if product.Comment[0].HelpfulCount != 2 {
+		t.Errorf("the migration lost the review fields: %+v", product.Comment[0])
+	}
+}
+
+func mustObjectID(t *testing.T, id string) primitive.ObjectID {
+	t.Helper()
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return objID
+}
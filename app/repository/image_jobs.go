@@ -0,0 +1,206 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"mgo-gin/app/images"
+	"mgo-gin/app/model"
+	"mgo-gin/db"
+	"mgo-gin/utils/logger"
+	"net/http"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Bucket de GridFS donde esperan los originales hasta que un worker los procesa
+const imageOriginalsBucket = "image_originals"
+
+// imageJobEntity es la cola de imágenes respaldada por Mongo; implementa jobs.Queue
+type imageJobEntity struct {
+	resource *db.Resource
+	repo     *mongo.Collection
+	products *mongo.Collection
+}
+
+func NewImageJobEntity(resource *db.Resource) *imageJobEntity {
+	return &imageJobEntity{
+		resource: resource,
+		repo:     resource.DB.Collection("image_jobs"),
+		products: resource.DB.Collection("product"),
+	}
+}
+
+// EnsureIndexes crea los índices que usan Claim y la consulta de estado
+func (entity *imageJobEntity) EnsureIndexes(ctx context.Context) error {
+	_, err := entity.repo.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "run_at", Value: 1}}},
+		{Keys: bson.D{{Key: "product_id", Value: 1}, {Key: "created_at", Value: -1}}},
+	})
+	return err
+}
+
+// bucket abre el bucket de originales con el deadline de ctx: GridFS no recibe
+// contextos en esta versión del driver
+func (entity *imageJobEntity) bucket(ctx context.Context) (*gridfs.Bucket, error) {
+	bucket, err := gridfs.NewBucket(entity.resource.DB, options.GridFSBucket().SetName(imageOriginalsBucket))
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = bucket.SetReadDeadline(deadline)
+		_ = bucket.SetWriteDeadline(deadline)
+	}
+	return bucket, nil
+}
+
+func (entity *imageJobEntity) SaveOriginal(ctx context.Context, filename string, data []byte) (primitive.ObjectID, error) {
+	ctx, cancel := initContext(ctx, entity.resource.QueryTimeout)
+	defer cancel()
+
+	bucket, err := entity.bucket(ctx)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	return bucket.UploadFromStream(filename, bytes.NewReader(data))
+}
+
+func (entity *imageJobEntity) ReadOriginal(ctx context.Context, id primitive.ObjectID) ([]byte, error) {
+	ctx, cancel := initContext(ctx, entity.resource.QueryTimeout)
+	defer cancel()
+
+	bucket, err := entity.bucket(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if _, err := bucket.DownloadToStream(id, &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (entity *imageJobEntity) deleteOriginal(ctx context.Context, id primitive.ObjectID) {
+	ctx, cancel := initContext(context.WithoutCancel(ctx), entity.resource.QueryTimeout)
+	defer cancel()
+
+	bucket, err := entity.bucket(ctx)
+	if err == nil {
+		err = bucket.Delete(id)
+	}
+	if err != nil && !errors.Is(err, gridfs.ErrFileNotFound) {
+		logger.FromContext(ctx).WithError(err).WithField("original_id", id.Hex()).Error("Error deleting original image")
+	}
+}
+
+// Enqueue deja el job pendiente para que lo tome el primer worker libre
+func (entity *imageJobEntity) Enqueue(ctx context.Context, job model.IImageJob) (model.IImageJob, error) {
+	ctx, cancel := initContext(ctx, entity.resource.QueryTimeout)
+	defer cancel()
+
+	now := time.Now().UTC()
+	job.Id = primitive.NewObjectID()
+	job.Status = model.ImageJobPending
+	job.RunAt = now
+	job.CreatedAt = now
+	job.UpdatedAt = now
+	if _, err := entity.repo.InsertOne(ctx, job); err != nil {
+		return model.IImageJob{}, err
+	}
+	return job, nil
+}
+
+// Claim reserva el job pendiente más antiguo, o uno en proceso cuyo lease venció
+// porque el worker que lo tenía murió, y cuenta el intento
+func (entity *imageJobEntity) Claim(ctx context.Context, lease time.Duration) (model.IImageJob, bool, error) {
+	ctx, cancel := initContext(ctx, entity.resource.QueryTimeout)
+	defer cancel()
+
+	now := time.Now().UTC()
+	filter := bson.M{"$or": []bson.M{
+		{"status": model.ImageJobPending, "run_at": bson.M{"$lte": now}},
+		{"status": model.ImageJobProcessing, "locked_until": bson.M{"$lt": now}},
+	}}
+	update := bson.M{
+		"$set": bson.M{"status": model.ImageJobProcessing, "locked_until": now.Add(lease), "updated_at": now},
+		"$inc": bson.M{"attempts": 1},
+	}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "run_at", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	var job model.IImageJob
+	err := entity.repo.FindOneAndUpdate(ctx, filter, update, opts).Decode(&job)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return model.IImageJob{}, false, nil
+	}
+	if err != nil {
+		return model.IImageJob{}, false, err
+	}
+	return job, true, nil
+}
+
+// Complete cierra el job y borra el original, que ya no hace falta
+func (entity *imageJobEntity) Complete(ctx context.Context, job model.IImageJob) error {
+	if err := entity.setStatus(ctx, job, model.ImageJobDone, "", time.Time{}); err != nil {
+		return err
+	}
+	entity.deleteOriginal(ctx, job.OriginalId)
+	return nil
+}
+
+func (entity *imageJobEntity) Retry(ctx context.Context, job model.IImageJob, runAt time.Time, err error) error {
+	return entity.setStatus(ctx, job, model.ImageJobPending, jobErrorMessage(err, "No se pudo procesar la imagen, se reintentará"), runAt)
+}
+
+// Fail marca el job y la imagen del producto como fallidos
+func (entity *imageJobEntity) Fail(ctx context.Context, job model.IImageJob, err error) error {
+	if err := entity.setStatus(ctx, job, model.ImageJobFailed, jobErrorMessage(err, "No se pudo procesar la imagen"), time.Time{}); err != nil {
+		return err
+	}
+	entity.deleteOriginal(ctx, job.OriginalId)
+
+	ctx, cancel := initContext(ctx, entity.resource.QueryTimeout)
+	defer cancel()
+	_, err = entity.products.UpdateOne(ctx, bson.M{"_id": job.ProductId}, bson.M{"$set": bson.M{"image_status": model.ImageStatusFailed}})
+	return err
+}
+
+func (entity *imageJobEntity) setStatus(ctx context.Context, job model.IImageJob, status string, message string, runAt time.Time) error {
+	ctx, cancel := initContext(ctx, entity.resource.QueryTimeout)
+	defer cancel()
+
+	set := bson.M{"status": status, "error": message, "locked_until": time.Time{}, "updated_at": time.Now().UTC()}
+	if !runAt.IsZero() {
+		set["run_at"] = runAt.UTC()
+	}
+	_, err := entity.repo.UpdateOne(ctx, bson.M{"_id": job.Id}, bson.M{"$set": set})
+	return err
+}
+
+// FindLatest devuelve el último job de la imagen de un producto
+func (entity *imageJobEntity) FindLatest(ctx context.Context, productID primitive.ObjectID) (model.IImageJob, int, error) {
+	ctx, cancel := initContext(ctx, entity.resource.QueryTimeout)
+	defer cancel()
+
+	var job model.IImageJob
+	opts := options.FindOne().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	if err := entity.repo.FindOne(ctx, bson.M{"product_id": productID}, opts).Decode(&job); err != nil {
+		return model.IImageJob{}, getHTTPCode(err), err
+	}
+	return job, http.StatusOK, nil
+}
+
+// jobErrorMessage solo muestra al cliente los errores de validación de la
+// imagen; para el resto usa fallback
+func jobErrorMessage(err error, fallback string) string {
+	if status := images.ErrorStatus(err); status < http.StatusInternalServerError {
+		return err.Error()
+	}
+	return fallback
+}
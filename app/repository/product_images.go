@@ -0,0 +1,618 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mgo-gin/app/images"
+	"mgo-gin/app/jobs"
+	"mgo-gin/app/model"
+	"mgo-gin/utils/logger"
+	"mgo-gin/utils/metrics"
+	"mgo-gin/utils/tracing"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// processImage decodifica la imagen subida, genera sus derivados y los sube en
+// paralelo. La extensión de cada archivo sale del formato real codificado.
+func (entity *productEntity) processImage(ctx context.Context, file io.ReadSeeker, filename string) (model.IProductImage, int, error) {
+	_, span := tracing.Start(ctx, "image.decode")
+	img, contentType, err := entity.imageProcessor.Decode(file)
+	span.SetAttributes(attribute.String("image.content_type", contentType))
+	tracing.End(span, err)
+	if err != nil {
+		logger.FromContext(ctx).WithError(err).WithField("content_type", contentType).Warn("Imagen rechazada")
+		return model.IProductImage{}, images.ErrorStatus(err), err
+	}
+
+	_, span = tracing.Start(ctx, "image.resize",
+		attribute.Int("image.width", img.Bounds().Dx()),
+		attribute.Int("image.height", img.Bounds().Dy()))
+	derivatives := entity.imageProcessor.Derive(img)
+	span.SetAttributes(attribute.Int("image.derivatives", len(derivatives)))
+	tracing.End(span, nil)
+
+	// Si el cliente se desconectó mientras se procesaba la imagen no vale la pena subirla
+	if err := ctx.Err(); err != nil {
+		return model.IProductImage{}, getHTTPCode(err), err
+	}
+
+	base := strings.TrimSuffix(filename, filepath.Ext(filename))
+	variants := make([]model.IImageVariant, len(derivatives))
+	errs := make([]error, len(derivatives))
+	var wg sync.WaitGroup
+	for i, derivative := range derivatives {
+		wg.Add(1)
+		go func(i int, derivative images.Derivative) {
+			defer wg.Done()
+			variants[i], errs[i] = entity.uploadDerivative(ctx, derivative, base)
+		}(i, derivative)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			// Los derivados que sí se subieron quedarían huérfanos
+			entity.deleteStoredImage(ctx, model.IProductImage{Variants: variants})
+			return model.IProductImage{}, getHTTPCode(err), err
+		}
+	}
+
+	result := model.IProductImage{Id: primitive.NewObjectID(), Variants: []model.IImageVariant{}}
+	for _, variant := range variants {
+		if variant.Name == images.ThumbnailName {
+			thumbnail := variant
+			result.Thumbnail = &thumbnail
+			continue
+		}
+		result.Variants = append(result.Variants, variant)
+	}
+	return result, http.StatusOK, nil
+}
+
+func (entity *productEntity) uploadDerivative(ctx context.Context, derivative images.Derivative, base string) (model.IImageVariant, error) {
+	_, span := tracing.Start(ctx, "image.encode",
+		attribute.String("image.format", entity.imageProcessor.Format().Name),
+		attribute.String("image.variant", derivative.Name))
+	encoded, format, err := entity.imageProcessor.Encode(derivative.Image)
+	span.SetAttributes(attribute.Int("image.bytes", encoded.Len()))
+	tracing.End(span, err)
+	if err != nil {
+		logger.FromContext(ctx).WithError(err).Error("Error codificando la imagen")
+		return model.IImageVariant{}, err
+	}
+
+	filename := base + "_" + derivative.Name + format.Ext
+	log := logger.FromContext(ctx).WithFields(logrus.Fields{"filename": filename, "format": format.Name, "bytes": encoded.Len()})
+	url, key, err := entity.storage.UploadImage(ctx, encoded, filename)
+	if err != nil {
+		log.WithError(err).Error("Error uploading image to storage")
+		return model.IImageVariant{}, err
+	}
+	log.WithField("url", url).Info("Image uploaded to storage")
+	return model.IImageVariant{
+		Name:   derivative.Name,
+		Url:    url,
+		Width:  derivative.Width(),
+		Height: derivative.Height(),
+		Key:    key,
+	}, nil
+}
+
+// ImageUpload es un archivo recibido para la galería con su texto alternativo
+type ImageUpload struct {
+	File     io.ReadSeeker
+	Filename string
+	Alt      string
+}
+
+func (entity *productEntity) GetImages(ctx context.Context, productid string) ([]model.IProductImage, int, error) {
+	product, statusCode, err := entity.GetOneProduct(ctx, productid)
+	if err != nil {
+		return []model.IProductImage{}, statusCode, err
+	}
+	if product.Images == nil {
+		return []model.IProductImage{}, http.StatusOK, nil
+	}
+	return product.Images, http.StatusOK, nil
+}
+
+// AddImages procesa y sube las imágenes y las agrega al final de la galería
+func (entity *productEntity) AddImages(ctx context.Context, productid string, uploads []ImageUpload) (model.IProducts, int, error) {
+	product, statusCode, err := entity.findProduct(ctx, productid)
+	if err != nil {
+		return model.IProducts{}, statusCode, err
+	}
+	if err := checkGallerySize(product, len(uploads)); err != nil {
+		return model.IProducts{}, http.StatusBadRequest, err
+	}
+
+	added := []model.IProductImage{}
+	// Si algo falla se borran del storage las imágenes ya subidas en esta petición
+	discardAdded := func() {
+		for i := range added {
+			entity.discardImage(ctx, &added[i])
+		}
+	}
+	for _, upload := range uploads {
+		productImage, statusCode, err := entity.processImage(ctx, upload.File, upload.Filename)
+		if err != nil {
+			discardAdded()
+			return model.IProducts{}, statusCode, err
+		}
+		productImage.Alt = upload.Alt
+		added = append(added, productImage)
+	}
+
+	// La subida puede tardar: editGallery vuelve a leer la galería antes de guardar
+	product, statusCode, err = entity.editGallery(ctx, productid, func(product *model.IProducts) (int, error) {
+		if err := checkGallerySize(*product, len(added)); err != nil {
+			return http.StatusBadRequest, err
+		}
+		product.Images = append(product.Images, added...)
+		return http.StatusOK, nil
+	})
+	if err != nil {
+		discardAdded()
+		return model.IProducts{}, statusCode, err
+	}
+	return product, statusCode, nil
+}
+
+func checkGallerySize(product model.IProducts, adding int) error {
+	if len(product.Images)+adding > model.MaxProductImages {
+		return fmt.Errorf("un producto admite como máximo %d imágenes", model.MaxProductImages)
+	}
+	return nil
+}
+
+func (entity *productEntity) UpdateImageAlt(ctx context.Context, productid string, imageid string, alt string) (model.IProductImage, int, error) {
+	var updated model.IProductImage
+	_, statusCode, err := entity.editGallery(ctx, productid, func(product *model.IProducts) (int, error) {
+		i, statusCode, err := findImage(*product, imageid)
+		if err != nil {
+			return statusCode, err
+		}
+		product.Images[i].Alt = alt
+		updated = product.Images[i]
+		return http.StatusOK, nil
+	})
+	if err != nil {
+		return model.IProductImage{}, statusCode, err
+	}
+	return updated, http.StatusOK, nil
+}
+
+func (entity *productEntity) SetPrimaryImage(ctx context.Context, productid string, imageid string) (model.IProducts, int, error) {
+	return entity.editGallery(ctx, productid, func(product *model.IProducts) (int, error) {
+		i, statusCode, err := findImage(*product, imageid)
+		if err != nil {
+			return statusCode, err
+		}
+		for j := range product.Images {
+			product.Images[j].Primary = j == i
+		}
+		return http.StatusOK, nil
+	})
+}
+
+// ReorderImages recibe todos los ids de la galería en el nuevo orden
+func (entity *productEntity) ReorderImages(ctx context.Context, productid string, imageids []string) (model.IProducts, int, error) {
+	return entity.editGallery(ctx, productid, func(product *model.IProducts) (int, error) {
+		if len(imageids) != len(product.Images) {
+			return http.StatusBadRequest, errors.New("el nuevo orden debe incluir todas las imágenes del producto")
+		}
+		byId := map[primitive.ObjectID]model.IProductImage{}
+		for _, productImage := range product.Images {
+			byId[productImage.Id] = productImage
+		}
+		ordered := []model.IProductImage{}
+		for _, imageid := range imageids {
+			objID, err := parseObjectID(imageid)
+			if err != nil {
+				return getHTTPCode(err), err
+			}
+			productImage, ok := byId[objID]
+			if !ok {
+				return http.StatusBadRequest, fmt.Errorf("la imagen %s no pertenece al producto o está repetida", imageid)
+			}
+			delete(byId, objID)
+			ordered = append(ordered, productImage)
+		}
+		product.Images = ordered
+		return http.StatusOK, nil
+	})
+}
+
+// DeleteImage quita la imagen de la galería y después borra sus archivos del
+// storage; si el borrado falla el producto ya no la referencia y solo se registra
+func (entity *productEntity) DeleteImage(ctx context.Context, productid string, imageid string) (model.IProducts, int, error) {
+	var removed model.IProductImage
+	product, statusCode, err := entity.editGallery(ctx, productid, func(product *model.IProducts) (int, error) {
+		i, statusCode, err := findImage(*product, imageid)
+		if err != nil {
+			return statusCode, err
+		}
+		removed = product.Images[i]
+		product.Images = append(product.Images[:i], product.Images[i+1:]...)
+		return http.StatusOK, nil
+	})
+	if err != nil {
+		return model.IProducts{}, statusCode, err
+	}
+
+	entity.deleteStoredImage(ctx, removed)
+	return product, http.StatusOK, nil
+}
+
+// deleteStoredImage borra del storage todas las versiones de una imagen que no
+// está (o ya no está) en la base. Los fallos solo se registran: lo que quede lo
+// limpia ReconcileStorage. No se corta aunque el cliente se haya desconectado.
+func (entity *productEntity) deleteStoredImage(ctx context.Context, productImage model.IProductImage) {
+	ctx = context.WithoutCancel(ctx)
+	for _, key := range productImage.Keys() {
+		if err := entity.storage.DeleteImage(ctx, key); err != nil {
+			logger.FromContext(ctx).WithError(err).WithField("key", key).Error("Error deleting image from storage")
+		}
+	}
+}
+
+// deleteReplacedImage borra del storage la imagen reemplazada salvo las claves
+// que también usa la nueva, para no dejar al producto apuntando a archivos borrados
+func (entity *productEntity) deleteReplacedImage(ctx context.Context, replaced model.IProductImage, current model.IProductImage) {
+	inUse := map[string]bool{}
+	for _, key := range current.Keys() {
+		inUse[key] = true
+	}
+	stale := model.IProductImage{Variants: []model.IImageVariant{}}
+	if replaced.Thumbnail != nil && !inUse[replaced.Thumbnail.Key] {
+		stale.Thumbnail = replaced.Thumbnail
+	}
+	for _, variant := range replaced.Variants {
+		if !inUse[variant.Key] {
+			stale.Variants = append(stale.Variants, variant)
+		}
+	}
+	entity.deleteStoredImage(ctx, stale)
+}
+
+// galleryRetries es cuántas veces se reintenta una edición de la galería cuando
+// otra escritura cambió el producto entre la lectura y el guardado
+const galleryRetries = 3
+
+var errGalleryConflict = errors.New("la galería cambió mientras se editaba, intenta de nuevo")
+
+// editGallery lee el producto, aplica edit sobre la galería y la guarda solo si
+// la versión no cambió desde la lectura; si cambió vuelve a empezar con el
+// producto actualizado. edit puede devolver un error para cancelar la edición.
+func (entity *productEntity) editGallery(ctx context.Context, productid string, edit func(product *model.IProducts) (int, error)) (model.IProducts, int, error) {
+	ctx, cancel := initContext(ctx, entity.resource.QueryTimeout)
+	defer cancel()
+
+	for attempt := 0; attempt < galleryRetries; attempt++ {
+		product, statusCode, err := entity.findProduct(ctx, productid)
+		if err != nil {
+			return model.IProducts{}, statusCode, err
+		}
+		if statusCode, err := edit(&product); err != nil {
+			return model.IProducts{}, statusCode, err
+		}
+		product, statusCode, err = entity.saveImages(ctx, product)
+		if errors.Is(err, ErrVersionMismatch) {
+			continue
+		}
+		if err != nil {
+			return model.IProducts{}, statusCode, err
+		}
+		hidePendingComments(&product)
+		return product, statusCode, nil
+	}
+	return model.IProducts{}, http.StatusConflict, errGalleryConflict
+}
+
+// saveImages garantiza una única imagen principal (la primera si no hay ninguna),
+// copia esa imagen a product.Image y guarda la galería. Solo escribe si la
+// versión sigue siendo la leída (si no devuelve ErrVersionMismatch) y la incrementa.
+func (entity *productEntity) saveImages(ctx context.Context, product model.IProducts) (model.IProducts, int, error) {
+	primary := -1
+	for i := range product.Images {
+		if product.Images[i].Primary && primary == -1 {
+			primary = i
+			continue
+		}
+		product.Images[i].Primary = false
+	}
+	if primary == -1 && len(product.Images) > 0 {
+		primary = 0
+		product.Images[0].Primary = true
+	}
+	product.Image = model.IProductImage{Variants: []model.IImageVariant{}}
+	if primary != -1 {
+		product.Image = product.Images[primary]
+	}
+	if product.Images == nil {
+		product.Images = []model.IProductImage{}
+	}
+
+	filter := bson.M{"_id": product.Id, "version": versionFilter([]int64{product.Version})}
+	updatePayload := bson.M{
+		"$set": bson.M{
+			"images": product.Images,
+			"image":  product.Image,
+		},
+		"$inc": bson.M{"version": 1},
+	}
+	result, err := entity.repo.UpdateOne(ctx, filter, updatePayload)
+	if err == nil && result.MatchedCount == 0 {
+		return model.IProducts{}, getHTTPCode(ErrVersionMismatch), ErrVersionMismatch
+	}
+	if err != nil {
+		logger.FromContext(ctx).WithError(err).Error("Error updating product images")
+		return model.IProducts{}, getHTTPCode(err), err
+	}
+	product.Version++
+	return product, http.StatusOK, nil
+}
+
+// findImage devuelve la posición de la imagen en la galería del producto
+func findImage(product model.IProducts, imageid string) (int, int, error) {
+	imageObjID, err := parseObjectID(imageid)
+	if err != nil {
+		return -1, getHTTPCode(err), err
+	}
+	for i, productImage := range product.Images {
+		if productImage.Id == imageObjID {
+			return i, http.StatusOK, nil
+		}
+	}
+	return -1, http.StatusNotFound, errors.New("imagen no encontrada")
+}
+
+// MigrateLegacyImages convierte la imagen de los productos creados antes de la
+// galería (image_url o un único image) en una galería con esa imagen como principal
+func (entity *productEntity) MigrateLegacyImages(ctx context.Context) error {
+	filter := bson.M{"images": bson.M{"$exists": false}}
+	cursor, err := entity.repo.Find(ctx, filter)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var legacy struct {
+			Id       primitive.ObjectID   `bson:"_id"`
+			ImageUrl string               `bson:"image_url"`
+			Image    *model.IProductImage `bson:"image"`
+		}
+		if err := cursor.Decode(&legacy); err != nil {
+			logger.FromContext(ctx).WithError(err).Error("Error decoding product")
+			continue
+		}
+		gallery := []model.IProductImage{}
+		switch {
+		case legacy.Image != nil && len(legacy.Image.Variants) > 0:
+			gallery = append(gallery, *legacy.Image)
+		case legacy.ImageUrl != "":
+			gallery = append(gallery, model.IProductImage{Variants: []model.IImageVariant{{Name: "original", Url: legacy.ImageUrl}}})
+		}
+		for i := range gallery {
+			if gallery[i].Id.IsZero() {
+				gallery[i].Id = primitive.NewObjectID()
+			}
+		}
+		image := model.IProductImage{Variants: []model.IImageVariant{}}
+		if len(gallery) > 0 {
+			gallery[0].Primary = true
+			image = gallery[0]
+		}
+		update := bson.M{"$set": bson.M{"image": image, "images": gallery}, "$unset": bson.M{"image_url": ""}}
+		if _, err := entity.repo.UpdateOne(ctx, bson.M{"_id": legacy.Id}, update); err != nil {
+			logger.FromContext(ctx).WithError(err).Error("Error migrating product images")
+		}
+	}
+	return cursor.Err()
+}
+
+// discardImage es la acción compensatoria cuando falla la escritura en Mongo
+// después de subir una imagen
+func (entity *productEntity) discardImage(ctx context.Context, productImage *model.IProductImage) {
+	if productImage == nil {
+		return
+	}
+	logger.FromContext(ctx).WithField("image_id", productImage.Id.Hex()).Warn("Rolling back uploaded image")
+	entity.deleteStoredImage(ctx, *productImage)
+}
+
+// ProcessImageJob es el handler de la cola de imágenes: procesa el original de
+// un producto nuevo y lo agrega a la galería, como principal si no hay otra. La imagen
+// lleva el id del job, así un reintento (o un segundo worker tras vencer el
+// lease) no la agrega dos veces.
+func (entity *productEntity) ProcessImageJob(ctx context.Context, job model.IImageJob) error {
+	product, statusCode, err := entity.findProduct(ctx, job.ProductId.Hex())
+	if statusCode == http.StatusNotFound {
+		// El producto se borró mientras tanto: no hay dónde poner la imagen
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, productImage := range product.Images {
+		if productImage.Id == job.Id {
+			// Un intento anterior ya la guardó y falló al cerrar el job
+			return nil
+		}
+	}
+
+	data, err := entity.imageJobs.ReadOriginal(ctx, job.OriginalId)
+	if errors.Is(err, gridfs.ErrFileNotFound) {
+		return jobs.Permanent(err)
+	}
+	if err != nil {
+		return err
+	}
+	productImage, statusCode, err := entity.processImage(ctx, bytes.NewReader(data), job.Filename)
+	if err != nil {
+		if statusCode < http.StatusInternalServerError {
+			return jobs.Permanent(err)
+		}
+		return err
+	}
+	productImage.Id = job.Id
+	productImage.Alt = job.Alt
+
+	// Galería, imagen principal, estado y versión en una sola escritura, y solo
+	// si la imagen del job todavía no está. Si mientras tanto un admin ya eligió
+	// una imagen principal, la del job se agrega al final sin reemplazarla.
+	queryCtx, cancel := initContext(ctx, entity.resource.QueryTimeout)
+	defer cancel()
+	images := bson.M{"$ifNull": bson.A{"$images", bson.A{}}}
+	hasPrimary := bson.M{"$anyElementTrue": bson.A{bson.M{"$map": bson.M{
+		"input": images,
+		"in":    bson.M{"$eq": bson.A{"$$this.primary", true}},
+	}}}}
+	primaryImage := productImage
+	primaryImage.Primary = true
+	update := []bson.M{{"$set": bson.M{
+		"images": bson.M{"$cond": bson.A{
+			hasPrimary,
+			bson.M{"$concatArrays": bson.A{images, bson.A{bson.M{"$literal": productImage}}}},
+			bson.M{"$concatArrays": bson.A{
+				bson.A{bson.M{"$literal": primaryImage}},
+				bson.M{"$map": bson.M{
+					"input": images,
+					"in":    bson.M{"$mergeObjects": bson.A{"$$this", bson.M{"primary": false}}},
+				}},
+			}},
+		}},
+		"image":        bson.M{"$cond": bson.A{hasPrimary, "$image", bson.M{"$literal": primaryImage}}},
+		"image_status": model.ImageStatusReady,
+		"version":      bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$version", 0}}, 1}},
+	}}}
+	filter := bson.M{"_id": job.ProductId, "images._id": bson.M{"$ne": job.Id}}
+	result, err := entity.repo.UpdateOne(queryCtx, filter, update)
+	if err != nil {
+		entity.discardImage(ctx, &productImage)
+		return err
+	}
+	if result.MatchedCount == 0 {
+		// El producto se borró o otro intento guardó la imagen primero
+		entity.discardImage(ctx, &productImage)
+	}
+	return nil
+}
+
+// GetImageStatus permite a los clientes consultar si la imagen de un producto
+// nuevo ya está lista
+func (entity *productEntity) GetImageStatus(ctx context.Context, productid string) (model.IImageStatus, int, error) {
+	product, statusCode, err := entity.findProduct(ctx, productid)
+	if err != nil {
+		return model.IImageStatus{}, statusCode, err
+	}
+	status := model.IImageStatus{ProductId: product.Id, ImageStatus: product.ImageStatus}
+	if status.ImageStatus == "" {
+		status.ImageStatus = model.ImageStatusReady
+	}
+	job, statusCode, err := entity.imageJobs.FindLatest(ctx, product.Id)
+	switch {
+	case err == nil:
+		status.Job = &job
+	case statusCode != http.StatusNotFound:
+		return model.IImageStatus{}, statusCode, err
+	}
+	return status, http.StatusOK, nil
+}
+
+// ReconcileStorage busca en el storage las imágenes subidas antes de olderThan
+// que no usa ningún producto (restos de fallos entre la subida y el guardado en
+// Mongo) y las borra, o solo las registra con dryRun. Devuelve cuántas encontró.
+func (entity *productEntity) ReconcileStorage(ctx context.Context, olderThan time.Time, dryRun bool) (int, error) {
+	// El storage se lista antes de leer los productos: una imagen que se guarda
+	// mientras tanto aparece en la lectura posterior
+	type storedImage struct{ key, url string }
+	stored := []storedImage{}
+	err := entity.storage.ListImages(ctx, olderThan, func(key string, url string) error {
+		stored = append(stored, storedImage{key: key, url: url})
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	keys, urls, err := entity.referencedImages(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	log := logger.FromContext(ctx)
+	orphans := 0
+	for _, image := range stored {
+		// Las imágenes migradas de image_url no tienen clave, solo URL
+		if keys[image.key] || urls[image.url] {
+			continue
+		}
+		orphans++
+		if dryRun {
+			log.WithField("key", image.key).WithField("url", image.url).Info("Orphaned image would be deleted (dry run)")
+			continue
+		}
+		if err := entity.storage.DeleteImage(ctx, image.key); err != nil {
+			log.WithError(err).WithField("key", image.key).Error("Error deleting orphaned image")
+			continue
+		}
+		metrics.OrphanedImagesDeleted.Inc()
+		log.WithField("key", image.key).Info("Orphaned image deleted")
+	}
+	return orphans, nil
+}
+
+// referencedImages devuelve las claves y URLs de todas las imágenes de productos
+func (entity *productEntity) referencedImages(ctx context.Context) (map[string]bool, map[string]bool, error) {
+	projection := bson.M{"image": 1, "images": 1, "image_url": 1}
+	cursor, err := entity.repo.Find(ctx, bson.M{}, options.Find().SetProjection(projection))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer cursor.Close(ctx)
+
+	keys, urls := map[string]bool{}, map[string]bool{}
+	add := func(productImage model.IProductImage) {
+		for _, key := range productImage.Keys() {
+			keys[key] = true
+		}
+		if productImage.Thumbnail != nil {
+			urls[productImage.Thumbnail.Url] = true
+		}
+		for _, variant := range productImage.Variants {
+			urls[variant.Url] = true
+		}
+	}
+	for cursor.Next(ctx) {
+		var product struct {
+			ImageUrl string                `bson:"image_url"`
+			Image    model.IProductImage   `bson:"image"`
+			Images   []model.IProductImage `bson:"images"`
+		}
+		// Un documento que no se puede leer podría referenciar imágenes: mejor no borrar nada
+		if err := cursor.Decode(&product); err != nil {
+			return nil, nil, err
+		}
+		urls[product.ImageUrl] = true
+		add(product.Image)
+		for _, productImage := range product.Images {
+			add(productImage)
+		}
+	}
+	return keys, urls, cursor.Err()
+}
@@ -3,21 +3,22 @@ package repository
 import (
 	"bytes"
 	"context"
-	"image"
-	"image/jpeg"
-	_ "image/png"
-	"log"
+	"errors"
+	"fmt"
+	"io"
 	"math"
-	"mgo-gin/app/cloudinary"
+	"mgo-gin/app/images"
 	"mgo-gin/app/model"
+	"mgo-gin/app/moderation"
+	"mgo-gin/app/storage"
 	"mgo-gin/db"
+	"mgo-gin/utils/logger"
+	"mgo-gin/utils/metrics"
 	"mime/multipart"
 	"net/http"
-	"path/filepath"
 	"strings"
 	"time"
 
-	"github.com/disintegration/imaging"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -25,133 +26,401 @@ import (
 )
 
 type productEntity struct {
-	resource          *db.Resource
-	repo              *mongo.Collection
-	cloudinaryService *cloudinary.CloudinaryService // Added CloudinaryService
+	resource       *db.Resource
+	repo           *mongo.Collection
+	storage        storage.Storage
+	contentFilter  *moderation.Pipeline
+	imageProcessor *images.Processor
+	imageJobs      *imageJobEntity
 }
 
 type IProduct interface {
-	GetAll(page, perPage int, search string, maxPrice float64, minPrice float64) (products []model.IProducts, totalCount int64, statusCode int, err error)
-	GetOneProduct(productid string) (product model.IProducts, statusCode int, err error)
-	CreateOne(productData model.ICreateProduct, imageFile multipart.File, imageFilename string) (model.IProducts, int, error)
-	UpdateProduct(productData model.ICreateProduct, productid string) (model.IProducts, int, error)
-	AddComment(productid string, username string, email string, comment model.IComment) (model.IProducts, int, error)
+	GetAll(ctx context.Context, page, perPage int, search string, maxPrice float64, minPrice float64, sortBy string) (products []model.IProducts, totalCount int64, statusCode int, err error)
+	GetOneProduct(ctx context.Context, productid string) (product model.IProducts, statusCode int, err error)
+	CreateOne(ctx context.Context, productData model.ICreateProduct, imageFile multipart.File, imageFilename string) (model.IProducts, int, error)
+	UpdateProduct(ctx context.Context, productData model.ICreateProduct, productid string, imageFile io.ReadSeeker, imageFilename string, expectedVersions []int64) (model.IProducts, int, error)
+	PatchProduct(ctx context.Context, productid string, patch model.IProductPatch, expectedVersions []int64) (model.IProducts, int, error)
+	AddComment(ctx context.Context, productid string, userId string, username string, email string, comment model.IComment) (model.IProducts, int, error)
+	GetComments(ctx context.Context, productid string, sortBy string) ([]model.ICommentData, int, error)
+	VoteComment(ctx context.Context, productid string, commentid string, userId string, helpful bool) (model.ICommentData, int, error)
+	ReportComment(ctx context.Context, productid string, commentid string, userId string, username string, reason string) (model.ICommentData, int, error)
+	GetReportedComments(ctx context.Context) ([]model.IReportedComment, int, error)
+	ResolveReportedComment(ctx context.Context, productid string, commentid string, action string) (model.IProducts, int, error)
+	MigrateCommentIds(ctx context.Context) error
+	MigrateLegacyImages(ctx context.Context) error
+	MigrateReviewCounts(ctx context.Context) error
+	EnsureIndexes(ctx context.Context) error
+	GetImages(ctx context.Context, productid string) ([]model.IProductImage, int, error)
+	AddImages(ctx context.Context, productid string, uploads []ImageUpload) (model.IProducts, int, error)
+	UpdateImageAlt(ctx context.Context, productid string, imageid string, alt string) (model.IProductImage, int, error)
+	SetPrimaryImage(ctx context.Context, productid string, imageid string) (model.IProducts, int, error)
+	ReorderImages(ctx context.Context, productid string, imageids []string) (model.IProducts, int, error)
+	DeleteImage(ctx context.Context, productid string, imageid string) (model.IProducts, int, error)
+	GetImageStatus(ctx context.Context, productid string) (model.IImageStatus, int, error)
 }
 
-// Updated NewProductEntity to accept CloudinaryService and return concrete type
-func NewProductEntity(resource *db.Resource, cldService *cloudinary.CloudinaryService) *productEntity {
+// NewProductEntity recibe el storage de imágenes elegido en la configuración
+func NewProductEntity(resource *db.Resource, imageStorage storage.Storage, contentFilter *moderation.Pipeline, imageProcessor *images.Processor) *productEntity {
 	productRepo := resource.DB.Collection("product")
 	return &productEntity{
-		resource:          resource,
-		repo:              productRepo,
-		cloudinaryService: cldService, // Initialize CloudinaryService
+		resource:       resource,
+		repo:           productRepo,
+		storage:        imageStorage,
+		contentFilter:  contentFilter,
+		imageProcessor: imageProcessor,
+		imageJobs:      NewImageJobEntity(resource),
 	}
 }
 
-func (entity *productEntity) AddComment(productid string, username string, email string, comment model.IComment) (model.IProducts, int, error) {
-	ctx, cancel := initContext()
+func (entity *productEntity) AddComment(ctx context.Context, productid string, userId string, username string, email string, comment model.IComment) (model.IProducts, int, error) {
+	ctx, cancel := initContext(ctx, entity.resource.QueryTimeout)
 	defer cancel()
 
-	objID, _ := primitive.ObjectIDFromHex(productid)
+	if comment.Rating < model.MinCommentRating || comment.Rating > model.MaxCommentRating {
+		return model.IProducts{}, http.StatusBadRequest, fmt.Errorf("la calificación debe estar entre %d y %d", model.MinCommentRating, model.MaxCommentRating)
+	}
 
-	product := model.IProducts{}
-	err := entity.repo.FindOne(ctx, bson.M{"_id": objID}).Decode(&product)
+	// El texto pasa por el filtro de contenido antes de guardarse
+	filtered := entity.contentFilter.Run(comment.Comment)
+	if filtered.Action == moderation.ActionReject {
+		return model.IProducts{}, http.StatusUnprocessableEntity, fmt.Errorf("la reseña fue rechazada por el filtro de contenido: %s", strings.Join(filtered.Reasons, ", "))
+	}
+	moderationResult := model.ICommentModeration{
+		Status:    model.ModerationApproved,
+		Reasons:   filtered.Reasons,
+		CheckedAt: time.Now().UTC(),
+	}
+	switch filtered.Action {
+	case moderation.ActionMask:
+		moderationResult.Status = model.ModerationMasked
+	case moderation.ActionHold:
+		moderationResult.Status = model.ModerationPending
+	}
+
+	objID, err := parseObjectID(productid)
 	if err != nil {
-		log.Printf("Error finding product: %v\n", err)
-		return model.IProducts{}, http.StatusInternalServerError, err
+		return model.IProducts{}, getHTTPCode(err), err
 	}
-	product.Comment = append(product.Comment, model.ICommentData{
-		Comment:   comment.Comment,
-		Rating:    comment.Rating,
-		Username:  username,
-		Email:     email,
-		CreatedAt: time.Now().UTC(),
+
+	// Un usuario solo puede tener una reseña por producto: si ya existe se
+	// actualiza en el lugar y si no se agrega solo mientras siga sin existir, así
+	// dos envíos simultáneos no crean dos reseñas ni se pisan con las de otros.
+	// Las reseñas antiguas no guardan user_id, por eso se compara también el username.
+	now := time.Now().UTC()
+	ownsQuery := bson.M{"$or": []bson.M{
+		{"user_id": userId},
+		{"user_id": bson.M{"$in": bson.A{"", nil}}, "username": username},
+	}}
+	ownsExpr := bson.M{"$or": bson.A{
+		bson.M{"$eq": bson.A{"$$c.user_id", userId}},
+		bson.M{"$and": bson.A{
+			bson.M{"$eq": bson.A{bson.M{"$ifNull": bson.A{"$$c.user_id", ""}}, ""}},
+			bson.M{"$eq": bson.A{"$$c.username", username}},
+		}},
+	}}
+	edit := setCommentFields(ownsExpr, bson.M{
+		"_id":        bson.M{"$ifNull": bson.A{"$$c._id", primitive.NewObjectID()}},
+		"user_id":    bson.M{"$literal": userId},
+		"comment":    bson.M{"$literal": filtered.Text},
+		"rating":     comment.Rating,
+		"moderation": bson.M{"$literal": moderationResult},
+		"email":      bson.M{"$literal": email},
+		"updated_at": bson.M{"$literal": now},
 	})
-	var TotalRating float64
-	if len(product.Comment) > 0 {
-		for _, comment := range product.Comment {
-			TotalRating += float64(comment.Rating)
-		}
-		averageRating := TotalRating / float64(len(product.Comment))
-		product.Rating = math.Round(averageRating*10) / 10
-	} else {
-		product.Rating = 1
+	added := bson.M{"$set": bson.M{"comment": bson.M{"$concatArrays": bson.A{
+		bson.M{"$ifNull": bson.A{"$comment", bson.A{}}},
+		bson.A{bson.M{"$literal": model.ICommentData{
+			Id:         primitive.NewObjectID(),
+			UserId:     userId,
+			Comment:    filtered.Text,
+			Rating:     comment.Rating,
+			Username:   username,
+			Email:      email,
+			Votes:      []model.ICommentVote{},
+			Reports:    []model.ICommentReport{},
+			Moderation: moderationResult,
+			CreatedAt:  now,
+			UpdatedAt:  now,
+		}}},
+	}}}}
+
+	hasReview := bson.M{"_id": objID, "comment": bson.M{"$elemMatch": ownsQuery}}
+	noReview := bson.M{"_id": objID, "comment": bson.M{"$not": bson.M{"$elemMatch": ownsQuery}}}
+	product, err := entity.updateComments(ctx, hasReview, edit)
+	statusCode := http.StatusOK
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		product, err = entity.updateComments(ctx, noReview, added)
+		statusCode = http.StatusCreated
 	}
-	updatePayload := bson.M{
-		"$set": bson.M{
-			"comment": product.Comment, // El campo BSON es "comment"
-			"rating":  product.Rating,
-		},
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		// Otro envío del mismo usuario agregó la reseña entre los dos intentos
+		product, err = entity.updateComments(ctx, hasReview, edit)
+		statusCode = http.StatusOK
 	}
-	_, err = entity.repo.UpdateOne(ctx, bson.M{"_id": objID}, updatePayload)
 	if err != nil {
-		log.Printf("Error updating product: %v\n", err)
-		return model.IProducts{}, http.StatusInternalServerError, err
+		logger.FromContext(ctx).WithError(err).Error("Error updating product")
+		return model.IProducts{}, getHTTPCode(err), err
 	}
-	return product, http.StatusOK, nil
+	if statusCode == http.StatusCreated {
+		metrics.ReviewsAdded.Inc()
+	}
+	hidePendingComments(&product)
+	return product, statusCode, nil
 }
 
-func (entity *productEntity) UpdateProduct(productData model.ICreateProduct, productid string) (model.IProducts, int, error) {
-	ctx, cancel := initContext()
-	defer cancel()
+// reviewCount cuenta las reseñas visibles, igual que averageRating
+func reviewCount(comments []model.ICommentData) int64 {
+	var count int64
+	for _, comment := range comments {
+		if comment.Moderation.Status != model.ModerationPending {
+			count++
+		}
+	}
+	return count
+}
 
-	objID, _ := primitive.ObjectIDFromHex(productid)
+// averageRating ignora las reseñas retenidas para moderación
+func averageRating(comments []model.ICommentData) float64 {
+	var totalRating float64
+	var count int
+	for _, comment := range comments {
+		if comment.Moderation.Status == model.ModerationPending {
+			continue
+		}
+		totalRating += float64(comment.Rating)
+		count++
+	}
+	if count == 0 {
+		return 1
+	}
+	averageRating := totalRating / float64(count)
+	return math.Round(averageRating*10) / 10
+}
+
+// hidePendingComments quita de la respuesta pública las reseñas retenidas para moderación
+func hidePendingComments(product *model.IProducts) {
+	visible := []model.ICommentData{}
+	for _, comment := range product.Comment {
+		if comment.Moderation.Status != model.ModerationPending {
+			visible = append(visible, comment)
+		}
+	}
+	product.Comment = visible
+}
+
+// UpdateProduct actualiza los datos del producto. Si llega imageFile pasa por el
+// mismo proceso que en CreateOne y reemplaza a la imagen principal; la anterior
+// se borra del storage solo después de guardar el producto. expectedVersions
+// funciona igual que en PatchProduct.
+func (entity *productEntity) UpdateProduct(ctx context.Context, productData model.ICreateProduct, productid string, imageFile io.ReadSeeker, imageFilename string, expectedVersions []int64) (model.IProducts, int, error) {
+	objID, err := parseObjectID(productid)
+	if err != nil {
+		return model.IProducts{}, getHTTPCode(err), err
+	}
+
+	// La imagen se procesa antes de leer el producto: la subida puede tardar
+	var newImage *model.IProductImage
+	if imageFile != nil {
+		productImage, statusCode, err := entity.processImage(ctx, imageFile, imageFilename)
+		if err != nil {
+			return model.IProducts{}, statusCode, err
+		}
+		newImage = &productImage
+	}
+
+	queryCtx, cancel := initContext(ctx, entity.resource.QueryTimeout)
+	defer cancel()
 
 	product := model.IProducts{}
-	err := entity.repo.FindOne(ctx, bson.M{"_id": objID}).Decode(&product)
+	err = entity.repo.FindOne(queryCtx, bson.M{"_id": objID}).Decode(&product)
 	if err != nil {
-		log.Printf("Error finding product: %v\n", err)
-		return model.IProducts{}, http.StatusInternalServerError, err
+		logger.FromContext(ctx).WithError(err).Error("Error finding product")
+		entity.discardImage(ctx, newImage)
+		return model.IProducts{}, getHTTPCode(err), err
+	}
+	if expectedVersions != nil && !containsVersion(expectedVersions, product.Version) {
+		entity.discardImage(ctx, newImage)
+		return model.IProducts{}, getHTTPCode(ErrVersionMismatch), ErrVersionMismatch
 	}
 	product.Title = productData.Title
 	product.Description = productData.Description
 	product.Price = productData.Price
-	_, err = entity.repo.UpdateOne(ctx, bson.M{"_id": objID}, bson.M{"$set": product})
+
+	var replaced *model.IProductImage
+	if newImage != nil {
+		replaced = replacePrimaryImage(&product, *newImage, productData.ImageAlt)
+	}
+
+	// Solo se escriben los campos editados: las reseñas leídas arriba pudieron
+	// cambiar mientras tanto. La galería sí se arma sobre lo leído, por eso la
+	// escritura exige que la versión siga siendo la leída.
+	set := bson.M{
+		"title":       product.Title,
+		"description": product.Description,
+		"price":       product.Price,
+	}
+	if newImage != nil {
+		set["image"] = product.Image
+		set["images"] = product.Images
+	}
+	filter := bson.M{"_id": objID, "version": versionFilter([]int64{product.Version})}
+	result, err := entity.repo.UpdateOne(queryCtx, filter, bson.M{"$set": set, "$inc": bson.M{"version": 1}})
+	if err == nil && result.MatchedCount == 0 {
+		err = ErrVersionMismatch
+	}
 	if err != nil {
-		log.Printf("Error updating product: %v\n", err)
-		return model.IProducts{}, http.StatusInternalServerError, err
+		logger.FromContext(ctx).WithError(err).Error("Error updating product")
+		entity.discardImage(ctx, newImage)
+		return model.IProducts{}, getHTTPCode(err), err
 	}
+	product.Version++
+
+	if replaced != nil {
+		entity.deleteReplacedImage(ctx, *replaced, *newImage)
+	}
+	hidePendingComments(&product)
 	return product, http.StatusOK, nil
 }
 
-func (entity *productEntity) GetOneProduct(productid string) (product model.IProducts, statusCode int, err error) {
-	ctx, cancel := initContext()
+// PatchProduct aplica los campos presentes en patch con una sola escritura.
+// Con expectedVersions (las versiones de If-Match) solo se actualiza si la
+// versión guardada es una de ellas; si no, devuelve 412 sin tocar el producto.
+func (entity *productEntity) PatchProduct(ctx context.Context, productid string, patch model.IProductPatch, expectedVersions []int64) (model.IProducts, int, error) {
+	ctx, cancel := initContext(ctx, entity.resource.QueryTimeout)
 	defer cancel()
 
-	objID, _ := primitive.ObjectIDFromHex(productid)
-	err = entity.repo.FindOne(ctx, bson.M{"_id": objID}).Decode(&product)
+	objID, err := parseObjectID(productid)
 	if err != nil {
-		log.Printf("Error finding product: %v\n", err)
-		return model.IProducts{}, http.StatusInternalServerError, err
+		return model.IProducts{}, getHTTPCode(err), err
+	}
+	filter := bson.M{"_id": objID}
+	if expectedVersions != nil {
+		filter["version"] = versionFilter(expectedVersions)
+	}
+
+	set := bson.M{}
+	if patch.Title != nil {
+		set["title"] = *patch.Title
+	}
+	if patch.Price != nil {
+		set["price"] = *patch.Price
+	}
+	if patch.Description != nil {
+		set["description"] = *patch.Description
+	}
+
+	product := model.IProducts{}
+	if len(set) == 0 {
+		// Un merge patch vacío no cambia nada, ni siquiera la versión
+		err = entity.repo.FindOne(ctx, filter).Decode(&product)
+	} else {
+		update := bson.M{"$set": set, "$inc": bson.M{"version": 1}}
+		opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+		err = entity.repo.FindOneAndUpdate(ctx, filter, update, opts).Decode(&product)
 	}
+	if errors.Is(err, mongo.ErrNoDocuments) && expectedVersions != nil {
+		// Si el producto existe lo que no coincidió fue la versión
+		if count, countErr := entity.repo.CountDocuments(ctx, bson.M{"_id": objID}); countErr == nil && count > 0 {
+			err = ErrVersionMismatch
+		}
+	}
+	if err != nil {
+		logger.FromContext(ctx).WithError(err).Error("Error patching product")
+		return model.IProducts{}, getHTTPCode(err), err
+	}
+	hidePendingComments(&product)
 	return product, http.StatusOK, nil
 }
-func (entity *productEntity) CreateOne(productData model.ICreateProduct, imageFile multipart.File, imageFilename string) (model.IProducts, int, error) {
 
-	img, _, err := image.Decode(imageFile)
-	if err != nil {
-		log.Printf("Error decodificando la imagen original: %v\n", err)
-		return model.IProducts{}, http.StatusInternalServerError, err
+// versionFilter acepta cualquiera de las versiones; los productos anteriores al
+// campo version no lo tienen y equivalen a la versión 0
+func versionFilter(versions []int64) bson.M {
+	values := []interface{}{}
+	for _, version := range versions {
+		values = append(values, version)
+		if version == 0 {
+			values = append(values, nil)
+		}
 	}
-	if img.Bounds().Dx() > 1200 { // Solo redimensionar si es más ancha de 1200px
-		img = imaging.Resize(img, 1200, 0, imaging.Lanczos)
+	return bson.M{"$in": values}
+}
+
+func containsVersion(versions []int64, version int64) bool {
+	for _, v := range versions {
+		if v == version {
+			return true
+		}
 	}
-	var webpBuffer bytes.Buffer
+	return false
+}
 
-	if err := jpeg.Encode(&webpBuffer, img, nil); err != nil {
-		log.Printf("Error codificando imagen a JPEG: %v\n", err)
-		return model.IProducts{}, http.StatusInternalServerError, err
+// replacePrimaryImage pone newImage en el lugar de la imagen principal de la
+// galería (o la agrega si no hay ninguna) y devuelve la reemplazada. Sin alt
+// nuevo se conserva el de la imagen anterior.
+func replacePrimaryImage(product *model.IProducts, newImage model.IProductImage, alt string) *model.IProductImage {
+	newImage.Primary = true
+	for i := range product.Images {
+		if !product.Images[i].Primary {
+			continue
+		}
+		replaced := product.Images[i]
+		newImage.Alt = alt
+		if alt == "" {
+			newImage.Alt = replaced.Alt
+		}
+		product.Images[i] = newImage
+		product.Image = newImage
+		return &replaced
+	}
+	newImage.Alt = alt
+	product.Images = append(product.Images, newImage)
+	product.Image = newImage
+	return nil
+}
+
+func (entity *productEntity) GetOneProduct(ctx context.Context, productid string) (product model.IProducts, statusCode int, err error) {
+	product, statusCode, err = entity.findProduct(ctx, productid)
+	if err != nil {
+		return model.IProducts{}, statusCode, err
+	}
+	hidePendingComments(&product)
+	return product, statusCode, nil
+}
+
+// findProduct devuelve el producto con todas sus reseñas, incluidas las retenidas
+func (entity *productEntity) findProduct(ctx context.Context, productid string) (product model.IProducts, statusCode int, err error) {
+	ctx, cancel := initContext(ctx, entity.resource.QueryTimeout)
+	defer cancel()
+
+	objID, err := parseObjectID(productid)
+	if err != nil {
+		return model.IProducts{}, getHTTPCode(err), err
+	}
+	err = entity.repo.FindOne(ctx, bson.M{"_id": objID}).Decode(&product)
+	if err != nil {
+		logger.FromContext(ctx).WithError(err).Error("Error finding product")
+		return model.IProducts{}, getHTTPCode(err), err
 	}
-	originalExt := filepath.Ext(imageFilename)
-	newImageFilename := strings.TrimSuffix(imageFilename, originalExt) + ".webp"
-	log.Printf("Imagen procesada a WebP: %s, tamaño nuevo: %d bytes\n", newImageFilename, webpBuffer.Len())
+	return product, http.StatusOK, nil
+}
 
-	imageUrl, err := entity.cloudinaryService.UploadImage(&webpBuffer, newImageFilename)
+// CreateOne valida la imagen sin decodificarla, guarda el original y crea el
+// producto con image_status pending: los workers de la cola generan y suben los
+// derivados en segundo plano (ver ProcessImageJob).
+func (entity *productEntity) CreateOne(ctx context.Context, productData model.ICreateProduct, imageFile multipart.File, imageFilename string) (model.IProducts, int, error) {
+	data, err := io.ReadAll(imageFile)
 	if err != nil {
-		log.Printf("Error uploading image to Cloudinary: %v\n", err)
-		return model.IProducts{}, http.StatusInternalServerError, err
+		return model.IProducts{}, http.StatusBadRequest, err
+	}
+	if contentType, err := entity.imageProcessor.Inspect(bytes.NewReader(data)); err != nil {
+		logger.FromContext(ctx).WithError(err).WithField("content_type", contentType).Warn("Imagen rechazada")
+		return model.IProducts{}, images.ErrorStatus(err), err
+	}
+	originalID, err := entity.imageJobs.SaveOriginal(ctx, imageFilename, data)
+	if err != nil {
+		logger.FromContext(ctx).WithError(err).Error("Error saving original image")
+		return model.IProducts{}, getHTTPCode(err), err
 	}
-	log.Printf("Image uploaded to Cloudinary: %s\n", imageUrl)
 
 	// Step 2: Prepare product data for MongoDB
 	newProduct := model.IProducts{
@@ -159,26 +428,90 @@ func (entity *productEntity) CreateOne(productData model.ICreateProduct, imageFi
 		Title:       productData.Title,
 		Description: productData.Description,
 		Price:       productData.Price,
-		ImageUrl:    imageUrl,
+		Image:       model.IProductImage{Variants: []model.IImageVariant{}},
+		Images:      []model.IProductImage{},
+		ImageStatus: model.ImageStatusPending,
+		Version:     1,
 		CreatedAt:   time.Now().UTC(),
 		Rating:      1,
 	}
 
 	// Step 3: Insert into MongoDB
-	// Using context.TODO() as a placeholder for your actual context logic
-	ctx := context.TODO()
-	_, err = entity.repo.InsertOne(ctx, newProduct)
+	insertCtx, cancel := initContext(ctx, entity.resource.QueryTimeout)
+	defer cancel()
+	_, err = entity.repo.InsertOne(insertCtx, newProduct)
 	if err != nil {
-		log.Printf("Error inserting product into MongoDB: %v\n", err)
-		return model.IProducts{}, http.StatusInternalServerError, err
+		logger.FromContext(ctx).WithError(err).Error("Error inserting product into MongoDB")
+		entity.imageJobs.deleteOriginal(ctx, originalID)
+		return model.IProducts{}, getHTTPCode(err), err
 	}
 
+	_, err = entity.imageJobs.Enqueue(ctx, model.IImageJob{
+		ProductId:  newProduct.Id,
+		OriginalId: originalID,
+		Filename:   imageFilename,
+		Alt:        productData.ImageAlt,
+	})
+	if err != nil {
+		// Sin job el producto se quedaría para siempre sin imagen: se deshace todo
+		logger.FromContext(ctx).WithError(err).Error("Error enqueuing image job")
+		entity.deleteProduct(ctx, newProduct.Id)
+		entity.imageJobs.deleteOriginal(ctx, originalID)
+		return model.IProducts{}, getHTTPCode(err), err
+	}
+	metrics.ProductsCreated.Inc()
+
 	return newProduct, http.StatusCreated, nil
 }
 
-func (entity *productEntity) GetAll(page, perPage int, search string, maxPrice float64, minPrice float64) ([]model.IProducts, int64, int, error) {
+// deleteProduct deshace la creación de un producto cuando falla un paso posterior
+func (entity *productEntity) deleteProduct(ctx context.Context, productID primitive.ObjectID) {
+	ctx, cancel := initContext(context.WithoutCancel(ctx), entity.resource.QueryTimeout)
+	defer cancel()
+	if _, err := entity.repo.DeleteOne(ctx, bson.M{"_id": productID}); err != nil {
+		logger.FromContext(ctx).WithError(err).WithField("product_id", productID.Hex()).Error("Error rolling back product")
+	}
+}
+
+// productSorts son los órdenes del listado. Todos terminan en _id para que el
+// orden sea estable entre páginas cuando hay empates; cada uno tiene su índice
+// en EnsureIndexes.
+var productSorts = map[string]bson.D{
+	model.ProductSortNewest:    {{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}},
+	model.ProductSortPriceAsc:  {{Key: "price", Value: 1}, {Key: "_id", Value: 1}},
+	model.ProductSortPriceDesc: {{Key: "price", Value: -1}, {Key: "_id", Value: -1}},
+	model.ProductSortRating:    {{Key: "rating", Value: -1}, {Key: "_id", Value: -1}},
+	model.ProductSortTitle:     {{Key: "title", Value: 1}, {Key: "_id", Value: 1}},
+	model.ProductSortPopular:   {{Key: "review_count", Value: -1}, {Key: "_id", Value: -1}},
+}
+
+// titleCollation ordena los títulos sin distinguir mayúsculas ni acentos; la
+// consulta y el índice tienen que usar la misma
+var titleCollation = &options.Collation{Locale: "es", Strength: 1}
+
+// EnsureIndexes crea un índice por cada orden de productSorts; Mongo los
+// recorre en los dos sentidos, así que price_asc y price_desc comparten uno
+func (entity *productEntity) EnsureIndexes(ctx context.Context) error {
+	_, err := entity.repo.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: productSorts[model.ProductSortNewest]},
+		{Keys: productSorts[model.ProductSortPriceAsc]},
+		{Keys: productSorts[model.ProductSortRating]},
+		{Keys: productSorts[model.ProductSortPopular]},
+		{Keys: productSorts[model.ProductSortTitle], Options: options.Index().SetCollation(titleCollation)},
+	})
+	return err
+}
+
+func (entity *productEntity) GetAll(ctx context.Context, page, perPage int, search string, maxPrice float64, minPrice float64, sortBy string) ([]model.IProducts, int64, int, error) {
 	productList := []model.IProducts{}
-	ctx := context.TODO() // Consider using a context propagated from the handler
+	ctx, cancel := initContext(ctx, entity.resource.QueryTimeout)
+	defer cancel()
+
+	sort, ok := productSorts[sortBy]
+	if !ok {
+		return []model.IProducts{}, 0, http.StatusBadRequest, fmt.Errorf("orden inválido, usa uno de: %s, %s, %s, %s, %s, %s",
+			model.ProductSortNewest, model.ProductSortPriceAsc, model.ProductSortPriceDesc, model.ProductSortRating, model.ProductSortTitle, model.ProductSortPopular)
+	}
 
 	if page < 1 {
 		page = 1
@@ -191,7 +524,10 @@ func (entity *productEntity) GetAll(page, perPage int, search string, maxPrice f
 	findOptions := options.Find()
 	findOptions.SetSkip(skip)
 	findOptions.SetLimit(int64(perPage))
-	// Example: findOptions.SetSort(bson.D{{"createdAt", -1}}) // Sort by creation_date descending
+	findOptions.SetSort(sort)
+	if sortBy == model.ProductSortTitle {
+		findOptions.SetCollation(titleCollation)
+	}
 
 	filter := bson.M{}
 	if search != "" {
@@ -211,21 +547,24 @@ func (entity *productEntity) GetAll(page, perPage int, search string, maxPrice f
 	}
 	cursor, err := entity.repo.Find(ctx, filter, findOptions) // Use entity.repo and the filter
 	if err != nil {
-		log.Printf("Error finding products: %v\n", err)
-		return []model.IProducts{}, 0, http.StatusInternalServerError, err
+		logger.FromContext(ctx).WithError(err).Error("Error finding products")
+		return []model.IProducts{}, 0, getHTTPCode(err), err
 	}
 	defer cursor.Close(ctx)
 
 	if err = cursor.All(ctx, &productList); err != nil {
-		log.Printf("Error decoding products: %v\n", err)
-		return []model.IProducts{}, 0, http.StatusInternalServerError, err
+		logger.FromContext(ctx).WithError(err).Error("Error decoding products")
+		return []model.IProducts{}, 0, getHTTPCode(err), err
+	}
+	for i := range productList {
+		hidePendingComments(&productList[i])
 	}
 
 	// Get total count of documents matching the filter for pagination
 	totalCount, err := entity.repo.CountDocuments(ctx, filter) // Use entity.repo and the same filter
 	if err != nil {
-		log.Printf("Error counting documents: %v\n", err)
-		return []model.IProducts{}, 0, http.StatusInternalServerError, err
+		logger.FromContext(ctx).WithError(err).Error("Error counting documents")
+		return []model.IProducts{}, 0, getHTTPCode(err), err
 	}
 
 	return productList, totalCount, http.StatusOK, nil
@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"context"
+	"mgo-gin/app/model"
+	"mgo-gin/db"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestAddCommentRatingBounds(t *testing.T) {
+	// La calificación se valida antes de tocar la base de datos
+	entity := &productEntity{resource: &db.Resource{QueryTimeout: time.Second}}
+	for _, rating := range []int64{-1, 0, model.MaxCommentRating + 1} {
+		_, statusCode, err := entity.AddComment(context.Background(), "", "u1", "ana", "ana@example.com", model.IComment{Comment: "hola", Rating: rating})
+		if err == nil || statusCode != http.StatusBadRequest {
+			t.Errorf("rating %d: got %d %v, want 400", rating, statusCode, err)
+		}
+	}
+}
+
+func TestAverageRating(t *testing.T) {
+	pending := model.ICommentModeration{Status: model.ModerationPending}
+	tests := []struct {
+		name     string
+		comments []model.ICommentData
+		want     float64
+		count    int64
+	}{
+		{"no reviews", nil, 1, 0},
+		{"rounded to one decimal", []model.ICommentData{{Rating: 5}, {Rating: 4}, {Rating: 4}}, 4.3, 3},
+		{"pending reviews do not count", []model.ICommentData{{Rating: 5}, {Rating: 1, Moderation: pending}}, 5, 1},
+		{"only pending reviews", []model.ICommentData{{Rating: 2, Moderation: pending}}, 1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := averageRating(tt.comments); got != tt.want {
+				t.Errorf("averageRating = %v, want %v", got, tt.want)
+			}
+			if got := reviewCount(tt.comments); got != tt.count {
+				t.Errorf("reviewCount = %d, want %d", got, tt.count)
+			}
+		})
+	}
+}
+
+func TestAddCommentEditsOrAdds(t *testing.T) {
+	entity := newTestEntity(t)
+	productid := insertTestProduct(t, entity)
+	ctx := context.Background()
+
+	product, statusCode, err := entity.AddComment(ctx, productid, "u1", "ana", "ana@example.com", model.IComment{Comment: "buena", Rating: 4})
+	if err != nil || statusCode != http.StatusCreated {
+		t.Fatalf("first review: %d %v", statusCode, err)
+	}
+	firstId := product.Comment[0].Id
+
+	product, statusCode, err = entity.AddComment(ctx, productid, "u1", "ana", "ana@example.com", model.IComment{Comment: "muy buena", Rating: 5})
+	if err != nil || statusCode != http.StatusOK {
+		t.Fatalf("second review from the same user: %d %v", statusCode, err)
+	}
+	if len(product.Comment) != 1 {
+		t.Fatalf("the same user has %d reviews, want 1", len(product.Comment))
+	}
+	if c := product.Comment[0]; c.Id != firstId || c.Comment != "muy buena" || c.Rating != 5 {
+		t.Errorf("review was not edited in place: %+v", c)
+	}
+
+	product, statusCode, err = entity.AddComment(ctx, productid, "u2", "beto", "beto@example.com", model.IComment{Comment: "$regular", Rating: 2})
+	if err != nil || statusCode != http.StatusCreated {
+		t.Fatalf("review from another user: %d %v", statusCode, err)
+	}
+	if len(product.Comment) != 2 || product.ReviewCount != 2 || product.Rating != 3.5 {
+		t.Errorf("got %d reviews, review_count %d, rating %v; want 2, 2, 3.5", len(product.Comment), product.ReviewCount, product.Rating)
+	}
+	if product.Comment[1].Comment != "$regular" {
+		t.Errorf("text starting with $ was read as an expression: %q", product.Comment[1].Comment)
+	}
+}
+
+func TestAddCommentConcurrentSubmissions(t *testing.T) {
+	entity := newTestEntity(t)
+	productid := insertTestProduct(t, entity)
+
+	const submissions = 8
+	errs := make(chan error, submissions)
+	for i := 0; i < submissions; i++ {
+		go func(rating int64) {
+			_, _, err := entity.AddComment(context.Background(), productid, "u1", "ana", "ana@example.com", model.IComment{Comment: "hola", Rating: rating})
+			errs <- err
+		}(int64(i%5 + 1))
+	}
+	for i := 0; i < submissions; i++ {
+		if err := <-errs; err != nil {
+			t.Errorf("AddComment: %v", err)
+		}
+	}
+	product, _, err := entity.findProduct(context.Background(), productid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(product.Comment) != 1 {
+		t.Errorf("concurrent submissions left %d reviews, want 1", len(product.Comment))
+	}
+}
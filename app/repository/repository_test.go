@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"context"
+	"mgo-gin/app/model"
+	"mgo-gin/app/moderation"
+	"mgo-gin/db"
+	"os"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// newTestEntity conecta a un MongoDB real (4.2 o superior, por los updates con
+// pipeline) en una base de datos temporal que se borra al terminar, por ejemplo:
+//
+//	docker run -p 27017:27017 mongo:6
+//	MONGO_TEST_URI=mongodb://localhost:27017 go test ./app/repository
+func newTestEntity(t *testing.T) *productEntity {
+	t.Helper()
+	uri := os.Getenv("MONGO_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGO_TEST_URI not set")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatalf("connecting to MongoDB: %v", err)
+	}
+	database := client.Database("mgo_gin_test_" + primitive.NewObjectID().Hex())
+	t.Cleanup(func() {
+		_ = database.Drop(context.Background())
+		_ = client.Disconnect(context.Background())
+	})
+	resource := &db.Resource{Client: client, DB: database, QueryTimeout: 10 * time.Second}
+	return NewProductEntity(resource, nil, moderation.NewPipeline(), nil)
+}
+
+// insertTestProduct guarda un producto sin reseñas y devuelve su id
+func insertTestProduct(t *testing.T, entity *productEntity) string {
+	t.Helper()
+	product := model.IProducts{
+		Id:      primitive.NewObjectID(),
+		Title:   "Taza",
+		Price:   10,
+		Images:  []model.IProductImage{},
+		Comment: []model.ICommentData{},
+	}
+	if _, err := entity.repo.InsertOne(context.Background(), product); err != nil {
+		t.Fatalf("inserting product: %v", err)
+	}
+	return product.Id.Hex()
+}
@@ -1,14 +1,15 @@
 package repository
 
 import (
+	"context"
 	"mgo-gin/app/form"
 	"mgo-gin/app/model"
 	"mgo-gin/db"
 	"mgo-gin/utils"
+	"mgo-gin/utils/logger"
 	"net/http"
 
 	"github.com/jinzhu/copier"
-	"github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -23,10 +24,10 @@ type toDoEntity struct {
 }
 
 type IToDo interface {
-	GetAll() ([]model.ICreateToDo, int, error)
-	CreateOne(todoForm model.ICreateToDo) (model.ICreateToDo, int, error)
-	GetOneByID(id string) (*model.ToDo, int, error) // need return pointer
-	Update(id string, todo form.ToDoForm) (model.ToDo, int, error)
+	GetAll(ctx context.Context) ([]model.ICreateToDo, int, error)
+	CreateOne(ctx context.Context, todoForm model.ICreateToDo) (model.ICreateToDo, int, error)
+	GetOneByID(ctx context.Context, id string) (*model.ToDo, int, error) // need return pointer
+	Update(ctx context.Context, id string, todo form.ToDoForm) (model.ToDo, int, error)
 }
 
 // func NewToDoEntity
@@ -36,28 +37,28 @@ func NewToDoEntity(resource *db.Resource) IToDo {
 	return ToDoEntity
 }
 
-func (entity *toDoEntity) GetAll() ([]model.ICreateToDo, int, error) {
+func (entity *toDoEntity) GetAll(ctx context.Context) ([]model.ICreateToDo, int, error) {
 	toDoList := []model.ICreateToDo{}
-	ctx, cancel := initContext()
+	ctx, cancel := initContext(ctx, entity.resource.QueryTimeout)
 	defer cancel()
 	cursor, err := entity.repo.Find(ctx, bson.M{})
 
 	if err != nil {
-		return []model.ICreateToDo{}, 400, err
+		return []model.ICreateToDo{}, getHTTPCode(err), err
 	}
 
 	for cursor.Next(ctx) {
 		var todo model.ICreateToDo
 		err = cursor.Decode(&todo)
 		if err != nil {
-			logrus.Print(err)
+			logger.FromContext(ctx).WithError(err).Error("Error decoding todo")
 		}
 		toDoList = append(toDoList, todo)
 	}
 	return toDoList, http.StatusOK, nil
 }
 
-func (entity *toDoEntity) CreateOne(todoForm model.ICreateToDo) (model.ICreateToDo, int, error) {
+func (entity *toDoEntity) CreateOne(ctx context.Context, todoForm model.ICreateToDo) (model.ICreateToDo, int, error) {
 	todo := model.ICreateToDo{
 		Id:          primitive.NewObjectID(),
 		Name:        todoForm.Name,
@@ -66,47 +67,47 @@ func (entity *toDoEntity) CreateOne(todoForm model.ICreateToDo) (model.ICreateTo
 		Description: todoForm.Description,
 	}
 
-	ctx, cancel := initContext()
+	ctx, cancel := initContext(ctx, entity.resource.QueryTimeout)
 	defer cancel()
 
 	_, err := entity.repo.InsertOne(ctx, todo)
 	if err != nil {
-		return model.ICreateToDo{}, 400, err
+		return model.ICreateToDo{}, getHTTPCode(err), err
 	}
 
 	return todo, http.StatusOK, nil
 }
 
-func (entity *toDoEntity) GetOneByID(id string) (*model.ToDo, int, error) {
+func (entity *toDoEntity) GetOneByID(ctx context.Context, id string) (*model.ToDo, int, error) {
 	var todo model.ToDo
-	ctx, cancel := initContext()
+	ctx, cancel := initContext(ctx, entity.resource.QueryTimeout)
 	defer cancel()
-	logrus.Print(id)
-	objID, _ := primitive.ObjectIDFromHex(id)
+	objID, err := parseObjectID(id)
+	if err != nil {
+		return nil, getHTTPCode(err), err
+	}
 
-	err := entity.repo.FindOne(ctx, bson.M{"_id": objID}).Decode(&todo)
+	err = entity.repo.FindOne(ctx, bson.M{"_id": objID}).Decode(&todo)
 	if err != nil {
-		return nil, http.StatusNotFound, err
+		return nil, getHTTPCode(err), err
 	}
 
 	return &todo, http.StatusOK, nil
 }
 
-func (entity *toDoEntity) Update(id string, todoForm form.ToDoForm) (model.ToDo, int, error) {
+func (entity *toDoEntity) Update(ctx context.Context, id string, todoForm form.ToDoForm) (model.ToDo, int, error) {
 	var todo *model.ToDo
-	ctx, cancel := initContext()
+	ctx, cancel := initContext(ctx, entity.resource.QueryTimeout)
 
 	defer cancel()
-	objID, _ := primitive.ObjectIDFromHex(id)
-
-	todo, _, err := entity.GetOneByID(id)
+	todo, statusCode, err := entity.GetOneByID(ctx, id)
 	if err != nil {
-		return model.ToDo{}, http.StatusNotFound, nil
+		return model.ToDo{}, statusCode, err
 	}
 
 	err = copier.Copy(todo, todoForm) // this is why we need return a pointer: to copy value
 	if err != nil {
-		logrus.Error(err)
+		logger.FromContext(ctx).WithError(err).Error("Error copying todo form")
 		return model.ToDo{}, getHTTPCode(err), err
 	}
 
@@ -114,7 +115,10 @@ func (entity *toDoEntity) Update(id string, todoForm form.ToDoForm) (model.ToDo,
 	opts := &options.FindOneAndUpdateOptions{
 		ReturnDocument: &isReturnNewDoc,
 	}
-	err = entity.repo.FindOneAndUpdate(ctx, bson.M{"_id": objID}, bson.M{"$set": todo}, opts).Decode(&todo)
+	err = entity.repo.FindOneAndUpdate(ctx, bson.M{"_id": todo.Id}, bson.M{"$set": todo}, opts).Decode(&todo)
+	if err != nil {
+		return model.ToDo{}, getHTTPCode(err), err
+	}
 
 	return *todo, http.StatusOK, nil
 }
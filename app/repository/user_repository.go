@@ -1,15 +1,16 @@
 package repository
 
 import (
+	"context"
 	"errors"
 	"mgo-gin/app/form"
 	"mgo-gin/app/model"
 	"mgo-gin/db"
 	"mgo-gin/utils/bcrypt"
 	"mgo-gin/utils/constant"
+	"mgo-gin/utils/logger"
 	"net/http"
 
-	"github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -23,9 +24,9 @@ type userEntity struct {
 }
 
 type IUser interface {
-	GetAll() ([]model.User, int, error)
-	GetOneByUsername(username string) (*model.ResponseUser, int, error)
-	CreateOne(userForm form.User) (*model.User, int, error)
+	GetAll(ctx context.Context) ([]model.User, int, error)
+	GetOneByUsername(ctx context.Context, username string) (*model.ResponseUser, int, error)
+	CreateOne(ctx context.Context, userForm form.User) (*model.User, int, error)
 }
 
 // func NewToDoEntity
@@ -35,30 +36,30 @@ func NewUserEntity(resource *db.Resource) IUser {
 	return UserEntity
 }
 
-func (entity *userEntity) GetAll() ([]model.User, int, error) {
+func (entity *userEntity) GetAll(ctx context.Context) ([]model.User, int, error) {
 	usersList := []model.User{}
-	ctx, cancel := initContext()
+	ctx, cancel := initContext(ctx, entity.resource.QueryTimeout)
 	defer cancel()
 	cursor, err := entity.repo.Find(ctx, bson.M{})
 
 	if err != nil {
-		logrus.Print(err)
-		return []model.User{}, 400, err
+		logger.FromContext(ctx).WithError(err).Error("Error querying users")
+		return []model.User{}, getHTTPCode(err), err
 	}
 
 	for cursor.Next(ctx) {
 		var user model.User
 		err = cursor.Decode(&user)
 		if err != nil {
-			logrus.Print(err)
+			logger.FromContext(ctx).WithError(err).Error("Error decoding user")
 		}
 		usersList = append(usersList, user)
 	}
 	return usersList, http.StatusOK, nil
 }
 
-func (entity *userEntity) GetOneByUsername(username string) (*model.ResponseUser, int, error) {
-	ctx, cancel := initContext()
+func (entity *userEntity) GetOneByUsername(ctx context.Context, username string) (*model.ResponseUser, int, error) {
+	ctx, cancel := initContext(ctx, entity.resource.QueryTimeout)
 	defer cancel()
 
 	var user model.ResponseUser
@@ -66,15 +67,17 @@ func (entity *userEntity) GetOneByUsername(username string) (*model.ResponseUser
 		entity.repo.FindOne(ctx, bson.M{"username": username}).Decode(&user)
 
 	if err != nil {
-		logrus.Print(err)
-		return nil, 400, err
+		if err != mongo.ErrNoDocuments {
+			logger.FromContext(ctx).WithError(err).Error("Error finding user")
+		}
+		return nil, getHTTPCode(err), err
 	}
 
 	return &user, http.StatusOK, nil
 }
 
-func (entity *userEntity) CreateOne(userForm form.User) (*model.User, int, error) {
-	ctx, cancel := initContext()
+func (entity *userEntity) CreateOne(ctx context.Context, userForm form.User) (*model.User, int, error) {
+	ctx, cancel := initContext(ctx, entity.resource.QueryTimeout)
 	defer cancel()
 
 	user := model.User{
@@ -84,15 +87,18 @@ func (entity *userEntity) CreateOne(userForm form.User) (*model.User, int, error
 		Roles:    constant.USER,
 		Email:    userForm.Email,
 	}
-	found, _, _ := entity.GetOneByUsername(user.Username)
+	found, statusCode, err := entity.GetOneByUsername(ctx, user.Username)
 	if found != nil {
-		return nil, http.StatusBadRequest, errors.New("Username is taken")
+		return nil, http.StatusBadRequest, errors.New("El nombre de usuario ya está en uso")
+	}
+	if statusCode != http.StatusNotFound {
+		return nil, statusCode, err
 	}
-	_, err := entity.repo.InsertOne(ctx, user)
+	_, err = entity.repo.InsertOne(ctx, user)
 
 	if err != nil {
-		logrus.Print(err)
-		return nil, 400, err
+		logger.FromContext(ctx).WithError(err).Error("Error inserting user")
+		return nil, getHTTPCode(err), err
 	}
 
 	return &user, http.StatusOK, nil
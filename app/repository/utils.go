@@ -2,21 +2,54 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"net/http"
-	"github.com/jinzhu/gorm"
 	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
-func initContext() (context.Context, context.CancelFunc) {
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
-	return ctx, cancel
+// ErrInvalidID se devuelve cuando un id recibido no es un ObjectID válido
+var ErrInvalidID = errors.New("id inválido")
+
+// ErrVersionMismatch se devuelve cuando If-Match no coincide con la versión guardada
+var ErrVersionMismatch = errors.New("el producto cambió desde que se leyó, vuelve a cargarlo e intenta de nuevo")
+
+// statusClientClosedRequest es el código que se registra cuando el cliente
+// se desconecta antes de recibir la respuesta
+const statusClientClosedRequest = 499
+
+// initContext limita una operación de Mongo al timeout configurado sin perder la
+// cancelación del contexto de la petición
+func initContext(parent context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(parent, timeout)
 }
 
+func parseObjectID(id string) (primitive.ObjectID, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return primitive.NilObjectID, ErrInvalidID
+	}
+	return objID, nil
+}
+
+// getHTTPCode traduce los errores de los repositorios a códigos HTTP: ids inválidos
+// son 400, documentos inexistentes 404 y cualquier otro fallo del driver 500
 func getHTTPCode(err error) int {
-	if err == gorm.ErrRecordNotFound {
+	switch {
+	case err == nil:
+		return http.StatusOK
+	case errors.Is(err, ErrInvalidID):
+		return http.StatusBadRequest
+	case errors.Is(err, mongo.ErrNoDocuments):
 		return http.StatusNotFound
-	} else if err == gorm.ErrUnaddressable || err == gorm.ErrCantStartTransaction || err == gorm.ErrInvalidSQL || err == gorm.ErrInvalidTransaction {
-		return http.StatusInternalServerError
+	case errors.Is(err, ErrVersionMismatch):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, context.Canceled):
+		return statusClientClosedRequest
 	}
-	return http.StatusBadRequest
-}
\ No newline at end of file
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"mgo-gin/config"
+	"mgo-gin/utils/metrics"
+	"mgo-gin/utils/tracing"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// LocalStorage guarda las imágenes en disco. Los archivos los sirve gin con
+// r.Static(URLPrefix(), Dir()), pensado para desarrollo y pruebas.
+type LocalStorage struct {
+	dir       string
+	urlPrefix string
+	baseURL   string
+}
+
+func NewLocalStorage(cfg config.LocalStorageConfig) (*LocalStorage, error) {
+	if err := os.MkdirAll(filepath.Join(cfg.Dir, "images"), 0o755); err != nil {
+		return nil, err
+	}
+	return &LocalStorage{
+		dir:       cfg.Dir,
+		urlPrefix: strings.TrimSuffix(cfg.URLPrefix, "/"),
+		baseURL:   strings.TrimSuffix(cfg.BaseURL, "/"),
+	}, nil
+}
+
+func (s *LocalStorage) Dir() string {
+	return s.dir
+}
+
+func (s *LocalStorage) URLPrefix() string {
+	return s.urlPrefix
+}
+
+func (s *LocalStorage) UploadImage(ctx context.Context, file *bytes.Buffer, filename string) (string, string, error) {
+	_, span := tracing.Start(ctx, "storage.local.upload", attribute.Int("storage.bytes", file.Len()))
+	start := time.Now()
+	key := objectKey(filename)
+	err := os.WriteFile(filepath.Join(s.dir, filepath.FromSlash(key)), file.Bytes(), 0o644)
+	metrics.ObserveUpload("local", start, err)
+	tracing.End(span, err)
+	if err != nil {
+		return "", "", err
+	}
+	return s.baseURL + s.urlPrefix + "/" + key, key, nil
+}
+
+// DeleteImage borra el archivo; si ya no existe no es un error
+func (s *LocalStorage) DeleteImage(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (s *LocalStorage) ListImages(ctx context.Context, olderThan time.Time, fn func(key string, url string) error) error {
+	entries, err := os.ReadDir(filepath.Join(s.dir, "images"))
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || !info.ModTime().Before(olderThan) {
+			continue
+		}
+		key := "images/" + entry.Name()
+		if err := fn(key, s.baseURL+s.urlPrefix+"/"+key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Ping comprueba que el directorio sigue existiendo
+func (s *LocalStorage) Ping(ctx context.Context) error {
+	info, err := os.Stat(s.dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return errors.New(s.dir + " is not a directory")
+	}
+	return nil
+}
+
+// path evita que una clave manipulada salga del directorio de uploads
+func (s *LocalStorage) path(key string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(key))
+	if filepath.IsAbs(clean) || clean == "." || strings.HasPrefix(clean, "..") {
+		return "", errors.New("invalid storage key " + key)
+	}
+	return filepath.Join(s.dir, clean), nil
+}
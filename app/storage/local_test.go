@@ -0,0 +1,25 @@
+package storage
+
+import (
+	"context"
+	"mgo-gin/config"
+	"testing"
+)
+
+func TestLocalStorageRoundTrip(t *testing.T) {
+	s, err := NewLocalStorage(config.LocalStorageConfig{Dir: t.TempDir(), URLPrefix: "/uploads", BaseURL: "http://localhost:8080"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testRoundTrip(t, s)
+}
+
+func TestLocalStorageRejectsKeysOutsideDir(t *testing.T) {
+	s, err := NewLocalStorage(config.LocalStorageConfig{Dir: t.TempDir(), URLPrefix: "/uploads"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteImage(context.Background(), "../outside.jpg"); err == nil {
+		t.Error("DeleteImage accepted a key outside the storage dir")
+	}
+}
@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mgo-gin/config"
+	"mgo-gin/utils/metrics"
+	"mgo-gin/utils/tracing"
+	"mime"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// S3Storage guarda las imágenes en un bucket de S3 o de un servicio compatible
+// como MinIO. El bucket debe permitir lectura pública de images/ o estar detrás
+// de una CDN configurada en PublicURL.
+type S3Storage struct {
+	client    *minio.Client
+	bucket    string
+	publicURL string
+	timeout   time.Duration
+}
+
+func NewS3Storage(cfg config.S3StorageConfig) (*S3Storage, error) {
+	// Se acepta el endpoint con o sin esquema: http://localhost:9000 o s3.amazonaws.com
+	endpoint, secure := cfg.Endpoint, cfg.UseSSL
+	if parsed, err := url.Parse(cfg.Endpoint); err == nil && parsed.Host != "" {
+		endpoint, secure = parsed.Host, parsed.Scheme == "https"
+	}
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+		Secure: secure,
+		Region: cfg.Region,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	publicURL := strings.TrimSuffix(cfg.PublicURL, "/")
+	if publicURL == "" {
+		scheme := "http"
+		if secure {
+			scheme = "https"
+		}
+		publicURL = fmt.Sprintf("%s://%s/%s", scheme, endpoint, cfg.Bucket)
+	}
+	return &S3Storage{client: client, bucket: cfg.Bucket, publicURL: publicURL, timeout: cfg.Timeout}, nil
+}
+
+func (s *S3Storage) UploadImage(ctx context.Context, file *bytes.Buffer, filename string) (string, string, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	key := objectKey(filename)
+	ctx, span := tracing.Start(ctx, "storage.s3.upload",
+		attribute.String("storage.key", key),
+		attribute.Int("storage.bytes", file.Len()))
+
+	start := time.Now()
+	_, err := s.client.PutObject(ctx, s.bucket, key, file, int64(file.Len()), minio.PutObjectOptions{
+		ContentType: mime.TypeByExtension(path.Ext(key)),
+		// Las claves son únicas, el archivo nunca cambia
+		CacheControl: "public, max-age=31536000, immutable",
+	})
+	metrics.ObserveUpload("s3", start, err)
+	tracing.End(span, err)
+	if err != nil {
+		return "", "", err
+	}
+	return s.publicURL + "/" + key, key, nil
+}
+
+// DeleteImage borra el objeto; S3 no devuelve error si ya no existe
+func (s *S3Storage) DeleteImage(ctx context.Context, key string) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	ctx, span := tracing.Start(ctx, "storage.s3.delete", attribute.String("storage.key", key))
+	err := s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{})
+	tracing.End(span, err)
+	return err
+}
+
+func (s *S3Storage) ListImages(ctx context.Context, olderThan time.Time, fn func(key string, url string) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	for object := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Prefix: "images/", Recursive: true}) {
+		if object.Err != nil {
+			return object.Err
+		}
+		if !object.LastModified.Before(olderThan) {
+			continue
+		}
+		if err := fn(object.Key, s.publicURL+"/"+object.Key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Ping comprueba las credenciales y que el bucket exista
+func (s *S3Storage) Ping(ctx context.Context) error {
+	exists, err := s.client.BucketExists(ctx, s.bucket)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("bucket %s does not exist", s.bucket)
+	}
+	return nil
+}
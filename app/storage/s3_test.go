@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"context"
+	"mgo-gin/config"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// TestS3StorageRoundTrip corre contra un S3 real o un MinIO local, por ejemplo:
+//
+//	docker run -p 9000:9000 minio/minio server /data
+//	S3_TEST_ENDPOINT=http://localhost:9000 S3_TEST_ACCESS_KEY=minioadmin S3_TEST_SECRET_KEY=minioadmin go test ./app/storage
+func TestS3StorageRoundTrip(t *testing.T) {
+	endpoint := os.Getenv("S3_TEST_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("S3_TEST_ENDPOINT not set")
+	}
+	bucket := os.Getenv("S3_TEST_BUCKET")
+	if bucket == "" {
+		bucket = "mgo-gin-test"
+	}
+	s, err := NewS3Storage(config.S3StorageConfig{
+		Endpoint:  endpoint,
+		Region:    "us-east-1",
+		Bucket:    bucket,
+		AccessKey: os.Getenv("S3_TEST_ACCESS_KEY"),
+		SecretKey: os.Getenv("S3_TEST_SECRET_KEY"),
+		Timeout:   30 * time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	exists, err := s.client.BucketExists(ctx, bucket)
+	if err != nil {
+		t.Fatalf("BucketExists: %v", err)
+	}
+	if !exists {
+		if err := s.client.MakeBucket(ctx, bucket, minio.MakeBucketOptions{Region: "us-east-1"}); err != nil {
+			t.Fatalf("MakeBucket: %v", err)
+		}
+	}
+	if err := s.Ping(ctx); err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+	testRoundTrip(t, s)
+}
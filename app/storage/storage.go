@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"mgo-gin/app/cloudinary"
+	"mgo-gin/config"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Storage guarda las imágenes de productos. UploadImage devuelve la URL pública
+// y la clave con la que después se puede borrar el archivo. ListImages recorre
+// las imágenes subidas antes de olderThan llamando a fn con su clave y su URL.
+type Storage interface {
+	UploadImage(ctx context.Context, file *bytes.Buffer, filename string) (string, string, error)
+	DeleteImage(ctx context.Context, key string) error
+	ListImages(ctx context.Context, olderThan time.Time, fn func(key string, url string) error) error
+	Ping(ctx context.Context) error
+}
+
+// New crea el backend elegido en cfg.Storage.Driver
+func New(cfg *config.Config) (Storage, error) {
+	switch cfg.Storage.Driver {
+	case config.StorageCloudinary:
+		return cloudinary.NewCloudinaryService(cfg.Cloudinary)
+	case config.StorageLocal:
+		return NewLocalStorage(cfg.Storage.Local)
+	case config.StorageS3:
+		return NewS3Storage(cfg.Storage.S3)
+	}
+	return nil, fmt.Errorf("unknown storage driver %q", cfg.Storage.Driver)
+}
+
+var unsafeChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// objectKey arma una clave única dentro de images/ a partir del nombre subido,
+// igual que hace Cloudinary con UniqueFilename
+func objectKey(filename string) string {
+	ext := path.Ext(filename)
+	base := unsafeChars.ReplaceAllString(strings.TrimSuffix(path.Base(filename), ext), "_")
+	if base == "" || base == "_" {
+		base = "image"
+	}
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix)
+	return "images/" + base + "_" + hex.EncodeToString(suffix) + strings.ToLower(ext)
+}
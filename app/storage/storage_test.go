@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestObjectKey(t *testing.T) {
+	tests := []struct {
+		filename string
+		prefix   string
+		ext      string
+	}{
+		{"photo.jpg", "images/photo_", ".jpg"},
+		{"Foto Playa.WEBP", "images/Foto_Playa_", ".webp"},
+		{"../../etc/passwd", "images/passwd_", ""},
+		{"???.png", "images/image_", ".png"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filename, func(t *testing.T) {
+			key := objectKey(tt.filename)
+			if !strings.HasPrefix(key, tt.prefix) || !strings.HasSuffix(key, tt.ext) {
+				t.Errorf("objectKey(%q) = %q, want %s<random>%s", tt.filename, key, tt.prefix, tt.ext)
+			}
+		})
+	}
+	if objectKey("photo.jpg") == objectKey("photo.jpg") {
+		t.Error("objectKey returned the same key twice for the same filename")
+	}
+}
+
+// testRoundTrip sube un archivo, lo encuentra con ListImages y lo borra
+func testRoundTrip(t *testing.T, s Storage) {
+	t.Helper()
+	ctx := context.Background()
+
+	url, key, err := s.UploadImage(ctx, bytes.NewBufferString("not really a jpeg"), "roundtrip.jpg")
+	if err != nil {
+		t.Fatalf("UploadImage: %v", err)
+	}
+	if !strings.HasSuffix(url, "/"+key) {
+		t.Errorf("url %q does not end with key %q", url, key)
+	}
+
+	if listed := listKeys(t, s, time.Now().Add(time.Minute)); listed[key] != url {
+		t.Errorf("ListImages did not return %s with url %s: %v", key, url, listed)
+	}
+	if listed := listKeys(t, s, time.Now().Add(-time.Hour)); listed[key] != "" {
+		t.Errorf("ListImages returned %s although it is newer than olderThan", key)
+	}
+
+	if err := s.DeleteImage(ctx, key); err != nil {
+		t.Fatalf("DeleteImage: %v", err)
+	}
+	if listed := listKeys(t, s, time.Now().Add(time.Minute)); listed[key] != "" {
+		t.Errorf("%s still listed after DeleteImage", key)
+	}
+	// Borrar de nuevo no es un error
+	if err := s.DeleteImage(ctx, key); err != nil {
+		t.Errorf("second DeleteImage: %v", err)
+	}
+}
+
+func listKeys(t *testing.T, s Storage, olderThan time.Time) map[string]string {
+	t.Helper()
+	keys := map[string]string{}
+	err := s.ListImages(context.Background(), olderThan, func(key string, url string) error {
+		keys[key] = url
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ListImages: %v", err)
+	}
+	return keys
+}
@@ -0,0 +1,760 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	LogFormatJSON = "json"
+	LogFormatText = "text"
+)
+
+// Dónde se guardan las imágenes de productos
+const (
+	StorageCloudinary = "cloudinary"
+	StorageLocal      = "local"
+	StorageS3         = "s3"
+)
+
+const (
+	ImageFormatWebP = "webp"
+	ImageFormatJPEG = "jpeg"
+)
+
+const (
+	TracingExporterNone   = "none"
+	TracingExporterStdout = "stdout"
+	TracingExporterOTLP   = "otlp"
+)
+
+// Claves por las que se agrupan las peticiones de una política de rate limit
+const (
+	RateLimitKeyIP     = "ip"
+	RateLimitKeyUser   = "user"
+	RateLimitKeyAPIKey = "api_key"
+)
+
+// Políticas que usan los grupos de rutas; deben existir en RateLimitConfig.Policies
+const (
+	RateLimitPolicyDefault = "default"
+	RateLimitPolicyAuth    = "auth"
+	RateLimitPolicyUpload  = "upload"
+)
+
+const (
+	EnvDevelopment = "development"
+	EnvProduction  = "production"
+	EnvTest        = "test"
+)
+
+// defaultJWTSecret solo se acepta fuera de producción
+const defaultJWTSecret = "uit_secret_key"
+
+type Config struct {
+	Env             string           `yaml:"env"`
+	Port            string           `yaml:"port"`
+	TrustedProxies  []string         `yaml:"trusted_proxies"`
+	ShutdownTimeout time.Duration    `yaml:"shutdown_timeout"`
+	Mongo           MongoConfig      `yaml:"mongo"`
+	Storage         StorageConfig    `yaml:"storage"`
+	Cloudinary      CloudinaryConfig `yaml:"cloudinary"`
+	Images          ImagesConfig     `yaml:"images"`
+	ImageJobs       ImageJobsConfig  `yaml:"image_jobs"`
+	JWT             JWTConfig        `yaml:"jwt"`
+	CORS            CORSConfig       `yaml:"cors"`
+	Reviews         ReviewsConfig    `yaml:"reviews"`
+	Health          HealthConfig     `yaml:"health"`
+	Log             LogConfig        `yaml:"log"`
+	Tracing         TracingConfig    `yaml:"tracing"`
+	RateLimit       RateLimitConfig  `yaml:"rate_limit"`
+}
+
+type MongoConfig struct {
+	URI            string        `yaml:"uri"`
+	Database       string        `yaml:"database"`
+	ConnectTimeout time.Duration `yaml:"connect_timeout"`
+	ConnectRetries int           `yaml:"connect_retries"`
+	RetryBackoff   time.Duration `yaml:"retry_backoff"`
+	QueryTimeout   time.Duration `yaml:"query_timeout"`
+	// MigrationTimeout limita las migraciones e índices que corren al arrancar
+	MigrationTimeout time.Duration `yaml:"migration_timeout"`
+}
+
+// StorageConfig elige el backend de imágenes. Si Driver está vacío se usa
+// Cloudinary cuando hay credenciales y el disco local si no.
+type StorageConfig struct {
+	Driver    string             `yaml:"driver"`
+	Local     LocalStorageConfig `yaml:"local"`
+	S3        S3StorageConfig    `yaml:"s3"`
+	Reconcile ReconcileConfig    `yaml:"reconcile"`
+}
+
+// ReconcileConfig controla la limpieza periódica de imágenes del storage que no
+// usa ningún producto. Solo se borran las subidas hace más de GracePeriod, así no
+// se tocan las que una petición o un job están por guardar. Interval 0 la desactiva
+// y con DryRun solo se registran.
+type ReconcileConfig struct {
+	Interval    time.Duration `yaml:"interval"`
+	GracePeriod time.Duration `yaml:"grace_period"`
+	DryRun      bool          `yaml:"dry_run"`
+}
+
+// LocalStorageConfig guarda los archivos en Dir y los sirve en URLPrefix;
+// BaseURL se antepone a las URLs devueltas si la API está detrás de otro host
+type LocalStorageConfig struct {
+	Dir       string `yaml:"dir"`
+	URLPrefix string `yaml:"url_prefix"`
+	BaseURL   string `yaml:"base_url"`
+}
+
+// S3StorageConfig sirve para AWS S3 o cualquier servicio compatible (MinIO).
+// PublicURL es la base de las URLs públicas; por defecto endpoint/bucket.
+type S3StorageConfig struct {
+	Endpoint  string        `yaml:"endpoint"`
+	Region    string        `yaml:"region"`
+	Bucket    string        `yaml:"bucket"`
+	AccessKey string        `yaml:"access_key"`
+	SecretKey string        `yaml:"secret_key"`
+	UseSSL    bool          `yaml:"use_ssl"`
+	PublicURL string        `yaml:"public_url"`
+	Timeout   time.Duration `yaml:"timeout"`
+}
+
+type CloudinaryConfig struct {
+	CloudName     string        `yaml:"cloud_name"`
+	APIKey        string        `yaml:"api_key"`
+	APISecret     string        `yaml:"api_secret"`
+	UploadTimeout time.Duration `yaml:"upload_timeout"`
+}
+
+// ImagesConfig controla cómo se guardan las imágenes de productos. Quality va de
+// 1 a 100; con Lossless (solo WebP) indica el esfuerzo de compresión. Widths son
+// los anchos para srcset y ThumbnailSize el lado de la miniatura cuadrada (0 la omite).
+// MaxUploadMB limita cada archivo subido y MaxMegapixels las dimensiones que se
+// aceptan antes de decodificar.
+type ImagesConfig struct {
+	MaxUploadMB   int    `yaml:"max_upload_mb"`
+	MaxMegapixels int    `yaml:"max_megapixels"`
+	Format        string `yaml:"format"`
+	Quality       int    `yaml:"quality"`
+	Lossless      bool   `yaml:"lossless"`
+	Widths        []int  `yaml:"widths"`
+	ThumbnailSize int    `yaml:"thumbnail_size"`
+}
+
+// ImageJobsConfig controla los workers que procesan en segundo plano las imágenes
+// de productos nuevos. Un job que falla se reintenta hasta MaxAttempts veces
+// esperando RetryBackoff, el doble en cada intento. Lease es cuánto puede tardar
+// un worker antes de que otro retome el job (por ejemplo si el proceso murió).
+type ImageJobsConfig struct {
+	Workers      int           `yaml:"workers"`
+	PollInterval time.Duration `yaml:"poll_interval"`
+	MaxAttempts  int           `yaml:"max_attempts"`
+	RetryBackoff time.Duration `yaml:"retry_backoff"`
+	Lease        time.Duration `yaml:"lease"`
+}
+
+type JWTConfig struct {
+	Secret     string        `yaml:"secret"`
+	Issuer     string        `yaml:"issuer"`
+	Audience   string        `yaml:"audience"`
+	Expiration time.Duration `yaml:"expiration"`
+}
+
+type CORSConfig struct {
+	AllowedOrigins   []string      `yaml:"allowed_origins"`
+	AllowedMethods   []string      `yaml:"allowed_methods"`
+	AllowedHeaders   []string      `yaml:"allowed_headers"`
+	ExposedHeaders   []string      `yaml:"exposed_headers"`
+	AllowCredentials bool          `yaml:"allow_credentials"`
+	MaxAge           time.Duration `yaml:"max_age"`
+}
+
+// defaultOrigins se usa cuando no se configuran orígenes para el entorno
+func defaultOrigins(env string) []string {
+	if env == EnvProduction {
+		return []string{"https://nay-sdreams-production.up.railway.app"}
+	}
+	return []string{"http://localhost:*", "http://127.0.0.1:*"}
+}
+
+type TracingConfig struct {
+	Exporter    string  `yaml:"exporter"`
+	Endpoint    string  `yaml:"endpoint"`
+	ServiceName string  `yaml:"service_name"`
+	SampleRatio float64 `yaml:"sample_ratio"`
+}
+
+// RateLimitPolicy es un token bucket: Requests peticiones cada Period, con ráfagas
+// de hasta Burst (Requests si es 0)
+type RateLimitPolicy struct {
+	Requests int           `yaml:"requests"`
+	Period   time.Duration `yaml:"period"`
+	Burst    int           `yaml:"burst"`
+	KeyBy    string        `yaml:"key_by"`
+}
+
+type RateLimitConfig struct {
+	Enabled  bool                       `yaml:"enabled"`
+	Policies map[string]RateLimitPolicy `yaml:"policies"`
+}
+
+type LogConfig struct {
+	Level  string `yaml:"level"`
+	Format string `yaml:"format"`
+}
+
+type HealthConfig struct {
+	Timeout      time.Duration `yaml:"timeout"`
+	CheckStorage bool          `yaml:"check_storage"`
+}
+
+// ReviewActions son las acciones que puede tomar el filtro de contenido de
+// reseñas, de menor a mayor severidad (ver moderation.ParseAction)
+var ReviewActions = []string{"allow", "mask", "hold", "reject"}
+
+func isReviewAction(name string) bool {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, action := range ReviewActions {
+		if action == name {
+			return true
+		}
+	}
+	return false
+}
+
+type ReviewsConfig struct {
+	BannedWords       []string `yaml:"banned_words"`
+	BannedWordsAction string   `yaml:"banned_words_action"`
+	LinkAction        string   `yaml:"link_action"`
+	ShoutingAction    string   `yaml:"shouting_action"`
+	MaxLength         int      `yaml:"max_length"`
+}
+
+func Default() Config {
+	return Config{
+		Env:             EnvDevelopment,
+		Port:            "8080",
+		ShutdownTimeout: 15 * time.Second,
+		Mongo: MongoConfig{
+			ConnectTimeout:   5 * time.Second,
+			ConnectRetries:   5,
+			RetryBackoff:     time.Second,
+			QueryTimeout:     10 * time.Second,
+			MigrationTimeout: 10 * time.Minute,
+		},
+		Storage: StorageConfig{
+			Local: LocalStorageConfig{
+				Dir:       "./uploads",
+				URLPrefix: "/uploads",
+			},
+			S3: S3StorageConfig{
+				Region:  "us-east-1",
+				UseSSL:  true,
+				Timeout: 30 * time.Second,
+			},
+			// Apagada por defecto: el storage puede tener archivos de otras apps o
+			// datos antiguos con URLs distintas, y lo que borra no se recupera
+			Reconcile: ReconcileConfig{
+				GracePeriod: 24 * time.Hour,
+				DryRun:      true,
+			},
+		},
+		Cloudinary: CloudinaryConfig{
+			UploadTimeout: 30 * time.Second,
+		},
+		Images: ImagesConfig{
+			MaxUploadMB:   10,
+			MaxMegapixels: 40,
+			Format:        defaultImageFormat(),
+			Quality:       80,
+			Widths:        []int{150, 400, 800, 1200},
+			ThumbnailSize: 150,
+		},
+		ImageJobs: ImageJobsConfig{
+			Workers:      2,
+			PollInterval: 2 * time.Second,
+			MaxAttempts:  5,
+			RetryBackoff: 10 * time.Second,
+			Lease:        5 * time.Minute,
+		},
+		JWT: JWTConfig{
+			Secret:     defaultJWTSecret,
+			Issuer:     "uit",
+			Audience:   "user",
+			Expiration: 30 * 24 * time.Hour,
+		},
+		CORS: CORSConfig{
+			AllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS", "HEAD"},
+			AllowedHeaders: []string{"Origin", "Accept", "Content-Type", "Authorization", "X-Requested-With", "X-Request-ID", "X-API-Key", "If-Match"},
+			ExposedHeaders: []string{"X-Request-ID", "ETag", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy", "Retry-After"},
+			MaxAge:         10 * time.Minute,
+		},
+		Reviews: ReviewsConfig{
+			BannedWordsAction: "mask",
+			LinkAction:        "hold",
+			ShoutingAction:    "hold",
+			MaxLength:         2000,
+		},
+		Health: HealthConfig{
+			Timeout: 2 * time.Second,
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: LogFormatJSON,
+		},
+		Tracing: TracingConfig{
+			Exporter:    TracingExporterNone,
+			ServiceName: "nays-dream-api",
+			SampleRatio: 1,
+		},
+		RateLimit: RateLimitConfig{
+			Enabled: true,
+			Policies: map[string]RateLimitPolicy{
+				RateLimitPolicyDefault: {Requests: 300, Period: time.Minute, KeyBy: RateLimitKeyIP},
+				RateLimitPolicyAuth:    {Requests: 10, Period: time.Minute, KeyBy: RateLimitKeyIP},
+				RateLimitPolicyUpload:  {Requests: 20, Period: time.Hour, Burst: 5, KeyBy: RateLimitKeyUser},
+			},
+		},
+	}
+}
+
+// Load arma la configuración en este orden, cada paso sobrescribe al anterior:
+// valores por defecto, archivo YAML opcional (CONFIG_FILE) y variables de entorno
+// (incluido .env). Devuelve todos los valores faltantes o inválidos a la vez.
+func Load() (*Config, error) {
+	if err := godotenv.Load(".env"); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("loading .env: %w", err)
+	}
+
+	cfg := Default()
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading config file: %w", err)
+		}
+		if err := yaml.Unmarshal(data, &cfg); err != nil {
+			return nil, fmt.Errorf("parsing config file %s: %w", path, err)
+		}
+	}
+
+	l := &envLoader{}
+	l.str("APP_ENV", &cfg.Env)
+	l.str("PORT", &cfg.Port)
+	l.list("TRUSTED_PROXIES", &cfg.TrustedProxies)
+	l.duration("SHUTDOWN_TIMEOUT", &cfg.ShutdownTimeout)
+	l.str("MONGO_HOST", &cfg.Mongo.URI)
+	l.str("MONGO_DB_NAME", &cfg.Mongo.Database)
+	l.duration("MONGO_CONNECT_TIMEOUT", &cfg.Mongo.ConnectTimeout)
+	l.int("MONGO_CONNECT_RETRIES", &cfg.Mongo.ConnectRetries)
+	l.duration("MONGO_RETRY_BACKOFF", &cfg.Mongo.RetryBackoff)
+	l.duration("MONGO_QUERY_TIMEOUT", &cfg.Mongo.QueryTimeout)
+	l.duration("MONGO_MIGRATION_TIMEOUT", &cfg.Mongo.MigrationTimeout)
+	l.str("STORAGE_DRIVER", &cfg.Storage.Driver)
+	l.str("STORAGE_LOCAL_DIR", &cfg.Storage.Local.Dir)
+	l.str("STORAGE_LOCAL_URL_PREFIX", &cfg.Storage.Local.URLPrefix)
+	l.str("STORAGE_LOCAL_BASE_URL", &cfg.Storage.Local.BaseURL)
+	l.duration("STORAGE_RECONCILE_INTERVAL", &cfg.Storage.Reconcile.Interval)
+	l.duration("STORAGE_RECONCILE_GRACE_PERIOD", &cfg.Storage.Reconcile.GracePeriod)
+	l.bool("STORAGE_RECONCILE_DRY_RUN", &cfg.Storage.Reconcile.DryRun)
+	l.str("S3_ENDPOINT", &cfg.Storage.S3.Endpoint)
+	l.str("S3_REGION", &cfg.Storage.S3.Region)
+	l.str("S3_BUCKET", &cfg.Storage.S3.Bucket)
+	l.str("S3_ACCESS_KEY_ID", &cfg.Storage.S3.AccessKey)
+	l.str("S3_SECRET_ACCESS_KEY", &cfg.Storage.S3.SecretKey)
+	l.bool("S3_USE_SSL", &cfg.Storage.S3.UseSSL)
+	l.str("S3_PUBLIC_URL", &cfg.Storage.S3.PublicURL)
+	l.duration("S3_TIMEOUT", &cfg.Storage.S3.Timeout)
+	l.str("CLOUDINARY_CLOUD_NAME", &cfg.Cloudinary.CloudName)
+	l.str("CLOUDINARY_API_KEY", &cfg.Cloudinary.APIKey)
+	l.str("CLOUDINARY_API_SECRET", &cfg.Cloudinary.APISecret)
+	l.duration("CLOUDINARY_UPLOAD_TIMEOUT", &cfg.Cloudinary.UploadTimeout)
+	l.int("IMAGE_MAX_UPLOAD_MB", &cfg.Images.MaxUploadMB)
+	l.int("IMAGE_MAX_MEGAPIXELS", &cfg.Images.MaxMegapixels)
+	l.str("IMAGE_FORMAT", &cfg.Images.Format)
+	l.int("IMAGE_QUALITY", &cfg.Images.Quality)
+	l.bool("IMAGE_LOSSLESS", &cfg.Images.Lossless)
+	l.intList("IMAGE_WIDTHS", &cfg.Images.Widths)
+	l.int("IMAGE_THUMBNAIL_SIZE", &cfg.Images.ThumbnailSize)
+	l.int("IMAGE_JOB_WORKERS", &cfg.ImageJobs.Workers)
+	l.duration("IMAGE_JOB_POLL_INTERVAL", &cfg.ImageJobs.PollInterval)
+	l.int("IMAGE_JOB_MAX_ATTEMPTS", &cfg.ImageJobs.MaxAttempts)
+	l.duration("IMAGE_JOB_RETRY_BACKOFF", &cfg.ImageJobs.RetryBackoff)
+	l.duration("IMAGE_JOB_LEASE", &cfg.ImageJobs.Lease)
+	l.str("JWT_SECRET", &cfg.JWT.Secret)
+	l.str("JWT_ISSUER", &cfg.JWT.Issuer)
+	l.str("JWT_AUDIENCE", &cfg.JWT.Audience)
+	l.duration("JWT_EXPIRATION", &cfg.JWT.Expiration)
+	l.list("CORS_ALLOWED_ORIGINS", &cfg.CORS.AllowedOrigins)
+	l.list("CORS_ALLOWED_METHODS", &cfg.CORS.AllowedMethods)
+	l.list("CORS_ALLOWED_HEADERS", &cfg.CORS.AllowedHeaders)
+	l.list("CORS_EXPOSED_HEADERS", &cfg.CORS.ExposedHeaders)
+	l.bool("CORS_ALLOW_CREDENTIALS", &cfg.CORS.AllowCredentials)
+	l.duration("CORS_MAX_AGE", &cfg.CORS.MaxAge)
+	if len(cfg.CORS.AllowedOrigins) == 0 {
+		cfg.CORS.AllowedOrigins = defaultOrigins(cfg.Env)
+	}
+	l.list("REVIEW_BANNED_WORDS", &cfg.Reviews.BannedWords)
+	l.str("REVIEW_BANNED_WORDS_ACTION", &cfg.Reviews.BannedWordsAction)
+	l.str("REVIEW_LINK_ACTION", &cfg.Reviews.LinkAction)
+	l.str("REVIEW_SHOUTING_ACTION", &cfg.Reviews.ShoutingAction)
+	l.int("REVIEW_MAX_LENGTH", &cfg.Reviews.MaxLength)
+	l.duration("HEALTH_TIMEOUT", &cfg.Health.Timeout)
+	l.bool("HEALTH_CHECK_CLOUDINARY", &cfg.Health.CheckStorage) // nombre anterior
+	l.bool("HEALTH_CHECK_STORAGE", &cfg.Health.CheckStorage)
+	l.str("LOG_LEVEL", &cfg.Log.Level)
+	l.str("LOG_FORMAT", &cfg.Log.Format)
+	l.str("TRACING_EXPORTER", &cfg.Tracing.Exporter)
+	l.str("OTEL_EXPORTER_OTLP_ENDPOINT", &cfg.Tracing.Endpoint)
+	l.str("OTEL_SERVICE_NAME", &cfg.Tracing.ServiceName)
+	l.float("TRACING_SAMPLE_RATIO", &cfg.Tracing.SampleRatio)
+	l.bool("RATE_LIMIT_ENABLED", &cfg.RateLimit.Enabled)
+	if cfg.RateLimit.Policies == nil {
+		cfg.RateLimit.Policies = map[string]RateLimitPolicy{}
+	}
+	l.rate("RATE_LIMIT_DEFAULT", cfg.RateLimit.Policies, RateLimitPolicyDefault)
+	l.rate("RATE_LIMIT_AUTH", cfg.RateLimit.Policies, RateLimitPolicyAuth)
+	l.rate("RATE_LIMIT_UPLOAD", cfg.RateLimit.Policies, RateLimitPolicyUpload)
+
+	// En producción el driver tiene que ser explícito: sin credenciales de
+	// Cloudinary no se cae en silencio al disco local del contenedor
+	if cfg.Storage.Driver == "" && !cfg.IsProduction() {
+		cfg.Storage.Driver = StorageLocal
+		if cfg.Cloudinary.CloudName != "" {
+			cfg.Storage.Driver = StorageCloudinary
+		}
+	}
+
+	problems := append(l.problems, cfg.validate()...)
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("invalid configuration:\n  - %s", strings.Join(problems, "\n  - "))
+	}
+	return &cfg, nil
+}
+
+// MaxUploadBytes es el tamaño máximo de cada imagen subida
+func (cfg ImagesConfig) MaxUploadBytes() int64 {
+	return int64(cfg.MaxUploadMB) << 20
+}
+
+// defaultImageFormat es WebP solo si este binario sabe codificarlo
+func defaultImageFormat() string {
+	if WebPSupported {
+		return ImageFormatWebP
+	}
+	return ImageFormatJPEG
+}
+
+func (cfg Config) IsProduction() bool {
+	return cfg.Env == EnvProduction
+}
+
+func (cfg Config) validate() []string {
+	problems := []string{}
+	switch cfg.Env {
+	case EnvDevelopment, EnvProduction, EnvTest:
+	default:
+		problems = append(problems, fmt.Sprintf("APP_ENV must be one of %s, %s, %s (got %q)", EnvDevelopment, EnvProduction, EnvTest, cfg.Env))
+	}
+	if port, err := strconv.Atoi(cfg.Port); err != nil || port < 1 || port > 65535 {
+		problems = append(problems, fmt.Sprintf("PORT must be a valid TCP port (got %q)", cfg.Port))
+	}
+	if cfg.ShutdownTimeout <= 0 {
+		problems = append(problems, "SHUTDOWN_TIMEOUT must be positive")
+	}
+	if cfg.Mongo.URI == "" {
+		problems = append(problems, "MONGO_HOST is required")
+	}
+	if cfg.Mongo.Database == "" {
+		problems = append(problems, "MONGO_DB_NAME is required")
+	}
+	if cfg.Mongo.ConnectTimeout <= 0 {
+		problems = append(problems, "MONGO_CONNECT_TIMEOUT must be positive")
+	}
+	if cfg.Mongo.ConnectRetries < 1 {
+		problems = append(problems, "MONGO_CONNECT_RETRIES must be at least 1")
+	}
+	if cfg.Mongo.RetryBackoff < 0 {
+		problems = append(problems, "MONGO_RETRY_BACKOFF must not be negative")
+	}
+	if cfg.Mongo.QueryTimeout <= 0 {
+		problems = append(problems, "MONGO_QUERY_TIMEOUT must be positive")
+	}
+	if cfg.Mongo.MigrationTimeout <= 0 {
+		problems = append(problems, "MONGO_MIGRATION_TIMEOUT must be positive")
+	}
+	switch cfg.Storage.Driver {
+	case "":
+		problems = append(problems, fmt.Sprintf("STORAGE_DRIVER is required in production (one of %s, %s, %s)", StorageCloudinary, StorageLocal, StorageS3))
+	case StorageCloudinary:
+		if cfg.Cloudinary.CloudName == "" {
+			problems = append(problems, "CLOUDINARY_CLOUD_NAME is required")
+		}
+		if cfg.Cloudinary.APIKey == "" {
+			problems = append(problems, "CLOUDINARY_API_KEY is required")
+		}
+		if cfg.Cloudinary.APISecret == "" {
+			problems = append(problems, "CLOUDINARY_API_SECRET is required")
+		}
+		if cfg.Cloudinary.UploadTimeout <= 0 {
+			problems = append(problems, "CLOUDINARY_UPLOAD_TIMEOUT must be positive")
+		}
+	case StorageLocal:
+		if cfg.Storage.Local.Dir == "" {
+			problems = append(problems, "STORAGE_LOCAL_DIR is required")
+		}
+		if !strings.HasPrefix(cfg.Storage.Local.URLPrefix, "/") || cfg.Storage.Local.URLPrefix == "/" {
+			problems = append(problems, "STORAGE_LOCAL_URL_PREFIX must be a path like /uploads")
+		}
+	case StorageS3:
+		if cfg.Storage.S3.Endpoint == "" {
+			problems = append(problems, "S3_ENDPOINT is required")
+		}
+		if cfg.Storage.S3.Bucket == "" {
+			problems = append(problems, "S3_BUCKET is required")
+		}
+		if cfg.Storage.S3.AccessKey == "" || cfg.Storage.S3.SecretKey == "" {
+			problems = append(problems, "S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY are required")
+		}
+		if cfg.Storage.S3.Timeout <= 0 {
+			problems = append(problems, "S3_TIMEOUT must be positive")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("STORAGE_DRIVER must be one of %s, %s, %s (got %q)", StorageCloudinary, StorageLocal, StorageS3, cfg.Storage.Driver))
+	}
+	if cfg.Storage.Reconcile.Interval < 0 {
+		problems = append(problems, "STORAGE_RECONCILE_INTERVAL must not be negative")
+	}
+	// Un job puede tener imágenes subidas y sin guardar durante todo su lease
+	if cfg.Storage.Reconcile.Interval > 0 && cfg.Storage.Reconcile.GracePeriod < cfg.ImageJobs.Lease {
+		problems = append(problems, "STORAGE_RECONCILE_GRACE_PERIOD must be at least IMAGE_JOB_LEASE")
+	}
+	if cfg.Images.MaxUploadMB < 1 {
+		problems = append(problems, "IMAGE_MAX_UPLOAD_MB must be at least 1")
+	}
+	if cfg.Images.MaxMegapixels < 1 {
+		problems = append(problems, "IMAGE_MAX_MEGAPIXELS must be at least 1")
+	}
+	if cfg.Images.Format != ImageFormatWebP && cfg.Images.Format != ImageFormatJPEG {
+		problems = append(problems, fmt.Sprintf("IMAGE_FORMAT must be %s or %s (got %q)", ImageFormatWebP, ImageFormatJPEG, cfg.Images.Format))
+	}
+	if cfg.Images.Format == ImageFormatWebP && !WebPSupported {
+		problems = append(problems, "IMAGE_FORMAT=webp needs a binary built with -tags webp and libwebp; use jpeg or rebuild")
+	}
+	if cfg.Images.Quality < 1 || cfg.Images.Quality > 100 {
+		problems = append(problems, "IMAGE_QUALITY must be between 1 and 100")
+	}
+	if len(cfg.Images.Widths) == 0 {
+		problems = append(problems, "IMAGE_WIDTHS needs at least one width")
+	}
+	for _, width := range cfg.Images.Widths {
+		if width < 1 {
+			problems = append(problems, fmt.Sprintf("IMAGE_WIDTHS must be positive (got %d)", width))
+		}
+	}
+	if cfg.Images.ThumbnailSize < 0 {
+		problems = append(problems, "IMAGE_THUMBNAIL_SIZE must not be negative")
+	}
+	if cfg.ImageJobs.Workers < 1 {
+		problems = append(problems, "IMAGE_JOB_WORKERS must be at least 1")
+	}
+	if cfg.ImageJobs.MaxAttempts < 1 {
+		problems = append(problems, "IMAGE_JOB_MAX_ATTEMPTS must be at least 1")
+	}
+	if cfg.ImageJobs.PollInterval <= 0 || cfg.ImageJobs.RetryBackoff <= 0 || cfg.ImageJobs.Lease <= 0 {
+		problems = append(problems, "IMAGE_JOB_POLL_INTERVAL, IMAGE_JOB_RETRY_BACKOFF and IMAGE_JOB_LEASE must be positive")
+	}
+	if cfg.JWT.Secret == "" {
+		problems = append(problems, "JWT_SECRET is required")
+	} else if cfg.IsProduction() && cfg.JWT.Secret == defaultJWTSecret {
+		problems = append(problems, "JWT_SECRET must be changed from the default in production")
+	}
+	if cfg.JWT.Expiration <= 0 {
+		problems = append(problems, "JWT_EXPIRATION must be positive")
+	}
+	for _, origin := range cfg.CORS.AllowedOrigins {
+		// Con credenciales un "*" reflejaría cualquier origen y expondría las sesiones
+		if origin == "*" && cfg.CORS.AllowCredentials {
+			problems = append(problems, "CORS_ALLOWED_ORIGINS cannot be * when CORS_ALLOW_CREDENTIALS is true")
+		}
+		if strings.Count(origin, "*") > 1 {
+			problems = append(problems, fmt.Sprintf("CORS origin %q may contain a single wildcard", origin))
+		}
+	}
+	if len(cfg.CORS.AllowedMethods) == 0 {
+		problems = append(problems, "CORS_ALLOWED_METHODS needs at least one method")
+	}
+	if cfg.CORS.MaxAge < 0 {
+		problems = append(problems, "CORS_MAX_AGE must not be negative")
+	}
+	for _, action := range []struct{ key, value string }{
+		{"REVIEW_BANNED_WORDS_ACTION", cfg.Reviews.BannedWordsAction},
+		{"REVIEW_LINK_ACTION", cfg.Reviews.LinkAction},
+		{"REVIEW_SHOUTING_ACTION", cfg.Reviews.ShoutingAction},
+	} {
+		if !isReviewAction(action.value) {
+			problems = append(problems, fmt.Sprintf("%s must be one of %s (got %q)", action.key, strings.Join(ReviewActions, ", "), action.value))
+		}
+	}
+	if cfg.Reviews.MaxLength < 0 {
+		problems = append(problems, "REVIEW_MAX_LENGTH must not be negative")
+	}
+	if cfg.Health.Timeout <= 0 {
+		problems = append(problems, "HEALTH_TIMEOUT must be positive")
+	}
+	if _, err := logrus.ParseLevel(cfg.Log.Level); err != nil {
+		problems = append(problems, fmt.Sprintf("LOG_LEVEL: %v", err))
+	}
+	if cfg.Log.Format != LogFormatJSON && cfg.Log.Format != LogFormatText {
+		problems = append(problems, fmt.Sprintf("LOG_FORMAT must be %s or %s (got %q)", LogFormatJSON, LogFormatText, cfg.Log.Format))
+	}
+	switch cfg.Tracing.Exporter {
+	case TracingExporterNone, TracingExporterStdout, TracingExporterOTLP:
+	default:
+		problems = append(problems, fmt.Sprintf("TRACING_EXPORTER must be one of %s, %s, %s (got %q)", TracingExporterNone, TracingExporterStdout, TracingExporterOTLP, cfg.Tracing.Exporter))
+	}
+	if cfg.Tracing.SampleRatio < 0 || cfg.Tracing.SampleRatio > 1 {
+		problems = append(problems, "TRACING_SAMPLE_RATIO must be between 0 and 1")
+	}
+	for _, name := range []string{RateLimitPolicyDefault, RateLimitPolicyAuth, RateLimitPolicyUpload} {
+		if _, ok := cfg.RateLimit.Policies[name]; !ok {
+			problems = append(problems, fmt.Sprintf("rate limit policy %q is required", name))
+		}
+	}
+	for name, policy := range cfg.RateLimit.Policies {
+		if policy.Requests < 1 || policy.Period <= 0 || policy.Burst < 0 {
+			problems = append(problems, fmt.Sprintf("rate limit policy %q needs positive requests and period", name))
+		}
+		switch policy.KeyBy {
+		case RateLimitKeyIP, RateLimitKeyUser, RateLimitKeyAPIKey:
+		default:
+			problems = append(problems, fmt.Sprintf("rate limit policy %q: key_by must be one of %s, %s, %s (got %q)", name, RateLimitKeyIP, RateLimitKeyUser, RateLimitKeyAPIKey, policy.KeyBy))
+		}
+	}
+	return problems
+}
+
+// envLoader lee variables de entorno acumulando los errores de formato
+type envLoader struct {
+	problems []string
+}
+
+func (l *envLoader) str(key string, target *string) {
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		*target = value
+	}
+}
+
+func (l *envLoader) int(key string, target *int) {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		l.problems = append(l.problems, fmt.Sprintf("%s must be an integer (got %q)", key, value))
+		return
+	}
+	*target = parsed
+}
+
+func (l *envLoader) float(key string, target *float64) {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		l.problems = append(l.problems, fmt.Sprintf("%s must be a number (got %q)", key, value))
+		return
+	}
+	*target = parsed
+}
+
+func (l *envLoader) bool(key string, target *bool) {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		l.problems = append(l.problems, fmt.Sprintf("%s must be true or false (got %q)", key, value))
+		return
+	}
+	*target = parsed
+}
+
+func (l *envLoader) duration(key string, target *time.Duration) {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		l.problems = append(l.problems, fmt.Sprintf("%s must be a duration like 30s or 24h (got %q)", key, value))
+		return
+	}
+	*target = parsed
+}
+
+// rate sobrescribe requests y period de una política con el formato "10/1m"
+func (l *envLoader) rate(key string, policies map[string]RateLimitPolicy, name string) {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return
+	}
+	requests, period, found := strings.Cut(value, "/")
+	parsedRequests, err := strconv.Atoi(strings.TrimSpace(requests))
+	parsedPeriod, periodErr := time.ParseDuration(strings.TrimSpace(period))
+	if !found || err != nil || periodErr != nil {
+		l.problems = append(l.problems, fmt.Sprintf("%s must look like 10/1m (got %q)", key, value))
+		return
+	}
+	policy := policies[name]
+	policy.Requests = parsedRequests
+	policy.Period = parsedPeriod
+	if policy.KeyBy == "" {
+		policy.KeyBy = RateLimitKeyIP
+	}
+	policies[name] = policy
+}
+
+func (l *envLoader) intList(key string, target *[]int) {
+	var items []string
+	l.list(key, &items)
+	if items == nil {
+		return
+	}
+	values := []int{}
+	for _, item := range items {
+		parsed, err := strconv.Atoi(item)
+		if err != nil {
+			l.problems = append(l.problems, fmt.Sprintf("%s must be a comma separated list of integers (got %q)", key, item))
+			return
+		}
+		values = append(values, parsed)
+	}
+	*target = values
+}
+
+func (l *envLoader) list(key string, target *[]string) {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return
+	}
+	items := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	*target = items
+}
@@ -0,0 +1,110 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// validConfig es la configuración por defecto con lo mínimo que exige validate
+func validConfig() Config {
+	cfg := Default()
+	cfg.Mongo.URI = "mongodb://localhost:27017"
+	cfg.Mongo.Database = "test"
+	cfg.Storage.Driver = StorageLocal
+	return cfg
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(cfg *Config)
+		want   string // fragmento del problema esperado; vacío si es válida
+	}{
+		{"defaults", func(cfg *Config) {}, ""},
+		{"missing mongo uri", func(cfg *Config) { cfg.Mongo.URI = "" }, "MONGO_HOST is required"},
+		{"bad port", func(cfg *Config) { cfg.Port = "99999" }, "PORT must be a valid TCP port"},
+		{"unknown storage driver", func(cfg *Config) { cfg.Storage.Driver = "ftp" }, "STORAGE_DRIVER must be one of"},
+		{"missing storage driver", func(cfg *Config) { cfg.Storage.Driver = "" }, "STORAGE_DRIVER is required"},
+		{"cloudinary without credentials", func(cfg *Config) { cfg.Storage.Driver = StorageCloudinary }, "CLOUDINARY_CLOUD_NAME is required"},
+		{"s3 without credentials", func(cfg *Config) {
+			cfg.Storage.Driver = StorageS3
+			cfg.Storage.S3.Endpoint = "minio:9000"
+			cfg.Storage.S3.Bucket = "images"
+		}, "S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY are required"},
+		{"no migration timeout", func(cfg *Config) { cfg.Mongo.MigrationTimeout = 0 }, "MONGO_MIGRATION_TIMEOUT must be positive"},
+		{"unknown review action", func(cfg *Config) { cfg.Reviews.LinkAction = "ban" }, "REVIEW_LINK_ACTION must be one of"},
+		{"review action case and spaces", func(cfg *Config) { cfg.Reviews.LinkAction = " Reject " }, ""},
+		{"unknown image format", func(cfg *Config) { cfg.Images.Format = "png" }, "IMAGE_FORMAT must be"},
+		{"reconcile grace shorter than the job lease", func(cfg *Config) {
+			cfg.Storage.Reconcile.Interval = time.Hour
+			cfg.Storage.Reconcile.GracePeriod = time.Minute
+		}, "STORAGE_RECONCILE_GRACE_PERIOD must be at least IMAGE_JOB_LEASE"},
+		{"reconcile disabled ignores the grace period", func(cfg *Config) {
+			cfg.Storage.Reconcile.GracePeriod = time.Minute
+		}, ""},
+		{"negative reconcile interval", func(cfg *Config) { cfg.Storage.Reconcile.Interval = -time.Hour }, "STORAGE_RECONCILE_INTERVAL must not be negative"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			tt.modify(&cfg)
+			problems := cfg.validate()
+			if tt.want == "" {
+				if len(problems) > 0 {
+					t.Errorf("unexpected problems: %v", problems)
+				}
+				return
+			}
+			for _, problem := range problems {
+				if strings.Contains(problem, tt.want) {
+					return
+				}
+			}
+			t.Errorf("problems %v do not mention %q", problems, tt.want)
+		})
+	}
+}
+
+func TestDefaultImageFormatMatchesBuild(t *testing.T) {
+	cfg := validConfig()
+	if WebPSupported != (cfg.Images.Format == ImageFormatWebP) {
+		t.Errorf("default IMAGE_FORMAT is %q with WebPSupported=%v", cfg.Images.Format, WebPSupported)
+	}
+	cfg.Images.Format = ImageFormatWebP
+	rejected := false
+	for _, problem := range cfg.validate() {
+		rejected = rejected || strings.Contains(problem, "IMAGE_FORMAT=webp")
+	}
+	if rejected == WebPSupported {
+		t.Errorf("IMAGE_FORMAT=webp rejected=%v with WebPSupported=%v", rejected, WebPSupported)
+	}
+}
+
+func TestReconcileIsOffByDefault(t *testing.T) {
+	reconcile := Default().Storage.Reconcile
+	if reconcile.Interval != 0 || !reconcile.DryRun {
+		t.Errorf("reconcile defaults = %+v, want disabled and dry run", reconcile)
+	}
+}
+
+func TestLoadStorageDriverFallback(t *testing.T) {
+	t.Setenv("MONGO_HOST", "mongodb://localhost:27017")
+	t.Setenv("MONGO_DB_NAME", "test")
+	t.Setenv("STORAGE_DRIVER", "")
+	t.Setenv("CLOUDINARY_CLOUD_NAME", "")
+
+	t.Setenv("APP_ENV", EnvDevelopment)
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Storage.Driver != StorageLocal {
+		t.Errorf("driver = %q, want local outside production", cfg.Storage.Driver)
+	}
+
+	t.Setenv("APP_ENV", EnvProduction)
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "STORAGE_DRIVER is required in production") {
+		t.Errorf("production without STORAGE_DRIVER: %v", err)
+	}
+}
@@ -0,0 +1,7 @@
+//go:build webp && cgo
+
+package config
+
+// WebPSupported indica si el binario puede codificar WebP. Es la única marca del
+// build tag: app/images solo compila su codificador cuando vale true.
+const WebPSupported = true
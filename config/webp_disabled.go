@@ -0,0 +1,5 @@
+//go:build !webp || !cgo
+
+package config
+
+const WebPSupported = false
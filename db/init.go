@@ -2,40 +2,99 @@ package db
 
 import (
 	"context"
-	"github.com/joho/godotenv"
+	"fmt"
+	"mgo-gin/config"
+	"mgo-gin/utils/metrics"
+	"mgo-gin/utils/tracing"
+	"time"
+
 	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/event"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
-	"log"
-	"os"
-	"time"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
 )
 
+// Resource owns the MongoDB client for the lifetime of the server
 type Resource struct {
-	DB *mongo.Database
+	Client *mongo.Client
+	DB     *mongo.Database
+	// QueryTimeout bounds each repository operation on top of the request context
+	QueryTimeout time.Duration
+}
+
+// Close disconnects the client, waiting for in-flight operations until ctx expires
+func (r *Resource) Close(ctx context.Context) error {
+	if r == nil || r.Client == nil {
+		return nil
+	}
+	logrus.Info("Closing all db connections")
+	return r.Client.Disconnect(ctx)
 }
 
-// Close use this method to close database connection
-func (r *Resource) Close() {
-	logrus.Warning("Closing all db connections")
+// Ping checks that the primary is reachable
+func (r *Resource) Ping(ctx context.Context) error {
+	return r.Client.Ping(ctx, readpref.Primary())
 }
 
-func InitResource() (*Resource, error) {
-	err := godotenv.Load(".env")
-	if err != nil {
-		log.Print(err)
+// InitResource connects to MongoDB and pings it, retrying with exponential
+// backoff so the server can start while the database is still coming up
+func InitResource(cfg config.MongoConfig) (*Resource, error) {
+	var lastErr error
+	backoff := cfg.RetryBackoff
+	for attempt := 1; attempt <= cfg.ConnectRetries; attempt++ {
+		client, err := connect(cfg)
+		if err == nil {
+			return &Resource{Client: client, DB: client.Database(cfg.Database), QueryTimeout: cfg.QueryTimeout}, nil
+		}
+		lastErr = err
+		if attempt == cfg.ConnectRetries {
+			break
+		}
+		logrus.Warnf("MongoDB not reachable (attempt %d/%d): %v, retrying in %s", attempt, cfg.ConnectRetries, err, backoff)
+		time.Sleep(backoff)
+		backoff *= 2
 	}
+	return nil, fmt.Errorf("connecting to MongoDB after %d attempts: %w", cfg.ConnectRetries, lastErr)
+}
 
-	host :=os.Getenv("MONGO_HOST")
-	dbName := os.Getenv("MONGO_DB_NAME")
-	mongoClient, err := mongo.NewClient(options.Client().ApplyURI(host))
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-
-	err = mongoClient.Connect(ctx)
+func connect(cfg config.MongoConfig) (*mongo.Client, error) {
+	monitor := combineMonitors(metrics.MongoMonitor(), tracing.MongoMonitor())
+	client, err := mongo.NewClient(options.Client().ApplyURI(cfg.URI).SetMonitor(monitor))
 	if err != nil {
 		return nil, err
 	}
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ConnectTimeout)
 	defer cancel()
 
-	return &Resource{DB: mongoClient.Database(dbName)}, nil
+	if err = client.Connect(ctx); err != nil {
+		return nil, err
+	}
+	if err = client.Ping(ctx, readpref.Primary()); err != nil {
+		_ = client.Disconnect(context.Background())
+		return nil, err
+	}
+	return client, nil
+}
+
+// combineMonitors lets several command monitors observe the same client,
+// since the driver only accepts one
+func combineMonitors(monitors ...*event.CommandMonitor) *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, e *event.CommandStartedEvent) {
+			for _, m := range monitors {
+				m.Started(ctx, e)
+			}
+		},
+		Succeeded: func(ctx context.Context, e *event.CommandSucceededEvent) {
+			for _, m := range monitors {
+				m.Succeeded(ctx, e)
+			}
+		},
+		Failed: func(ctx context.Context, e *event.CommandFailedEvent) {
+			for _, m := range monitors {
+				m.Failed(ctx, e)
+			}
+		},
+	}
 }
@@ -3,6 +3,9 @@ package middlewares
 import (
 	"fmt"
 	"mgo-gin/app/model"
+	"mgo-gin/config"
+	err2 "mgo-gin/utils/err"
+	"mgo-gin/utils/logger"
 	"net/http"
 	"strings"
 	"time"
@@ -11,8 +14,13 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-// Create the JWT key used to create the signature
-var jwtKey = []byte("uit_secret_key")
+// jwtConfig holds the key used to sign tokens, set at startup with SetJWTConfig
+var jwtConfig = config.Default().JWT
+
+// SetJWTConfig configures the signing key, issuer, audience and lifetime of tokens
+func SetJWTConfig(cfg config.JWTConfig) {
+	jwtConfig = cfg
+}
 
 type Claims struct {
 	Id       string `json:"_id"`
@@ -30,57 +38,56 @@ func GenerateJWTToken(user model.ResponseUser) string {
 		"password": user.Password,
 		"roles":    user.Roles,
 		"email":    user.Email,
-		"aud":      "user",
-		"iss":      "uit",
-		"exp":      time.Now().Add(time.Hour * 24 * 30).Unix(),
+		"aud":      jwtConfig.Audience,
+		"iss":      jwtConfig.Issuer,
+		"exp":      time.Now().Add(jwtConfig.Expiration).Unix(),
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signedToken, _ := token.SignedString([]byte("uit_secret_key"))
+	signedToken, _ := token.SignedString([]byte(jwtConfig.Secret))
 	return signedToken
 }
 
+func parseToken(tokenString string) (*Claims, *jwt.Token, error) {
+	claims := &Claims{} // Usamos nuestra struct Claims
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		// Valida el algoritmo de firma
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("método de firma inesperado: %v", token.Header["alg"])
+		}
+		return []byte(jwtConfig.Secret), nil
+	})
+	return claims, token, err
+}
+
 func AuthRequired() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "required.authorization"})
-			c.Abort()
+			err2.Abort(c, err2.New(http.StatusUnauthorized, err2.CodeUnauthorized, "Se requiere el encabezado de autorización"))
 			return
 		}
 
 		parts := strings.Split(authHeader, " ")
 		if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "El formato del encabezado de autorización debe ser Bearer {token}"})
-			c.Abort()
+			err2.Abort(c, err2.New(http.StatusUnauthorized, err2.CodeUnauthorized, "El formato del encabezado de autorización debe ser Bearer {token}"))
 			return
 		}
 
 		tokenString := parts[1]
-		claims := &Claims{} // Usamos nuestra struct Claims
-
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			// Valida el algoritmo de firma
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("método de firma inesperado: %v", token.Header["alg"])
-			}
-			return jwtKey, nil
-		})
+		claims, token, err := parseToken(tokenString)
 
 		if err != nil {
 			if err == jwt.ErrSignatureInvalid {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "Firma de token inválida"})
-				c.Abort()
+				err2.Abort(c, err2.New(http.StatusUnauthorized, err2.CodeUnauthorized, "Firma de token inválida"))
 				return
 			}
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Token inválido: " + err.Error()}) // Podría ser un token malformado o expirado
-			c.Abort()
+			err2.Abort(c, err2.New(http.StatusUnauthorized, err2.CodeUnauthorized, "Token inválido: "+err.Error())) // Podría ser un token malformado o expirado
 			return
 		}
 
 		if !token.Valid {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token inválido"})
-			c.Abort()
+			err2.Abort(c, err2.New(http.StatusUnauthorized, err2.CodeUnauthorized, "Token inválido"))
 			return
 		}
 
@@ -89,6 +96,7 @@ func AuthRequired() gin.HandlerFunc {
 		c.Set("username", claims.Username)
 		c.Set("email", claims.Email) // Asegúrate que 'email' esté en tus claims
 		c.Set("roles", claims.Roles)
+		c.Request = c.Request.WithContext(logger.WithUserID(c.Request.Context(), claims.Id))
 		// También podrías guardar el struct Claims completo:
 		// c.Set("user_claims", claims)
 
@@ -1,44 +1,22 @@
 package middlewares
 
 import (
-	"github.com/dgrijalva/jwt-go"
-	"github.com/gin-gonic/gin"
-	"github.com/sirupsen/logrus"
+	err2 "mgo-gin/utils/err"
 	"net/http"
 	"strings"
-)
 
-func GetRolesFromToken(tokenReq string) (role []string) {
-	token, _ := jwt.Parse(tokenReq, func(token *jwt.Token) (interface{}, error) {
-		return []byte(""), nil
-	})
-	claim := token.Claims.(jwt.MapClaims)
-	var roles []string
-	rolesResource := claim["roles"].([]interface{})
-	for _, role := range rolesResource {
-		roles = append(roles, role.(string))
-	}
-	if len(roles) <= 0 {
-		return nil
-	}
-	return roles
-}
+	"github.com/gin-gonic/gin"
+)
 
-func RequireAuthorization(auths ...string)  gin.HandlerFunc {
+func RequireAuthorization(auths ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		token:=c.GetHeader("Authorization")
-		if token ==""{
-			logrus.Print("abc")
-			c.Abort()
-			c.Writer.WriteHeader(http.StatusUnauthorized)
-			return
-		}
-		jwtToken :=strings.Split(token,"Bearer ")
-		roles := GetRolesFromToken(jwtToken[1])
-		if len(roles) <= 0 {
-			invalidRequest(c)
+		// AuthRequired ya validó el token y dejó los roles en el contexto
+		rolesClaim := c.GetString("roles")
+		if rolesClaim == "" {
+			err2.Abort(c, err2.New(http.StatusUnauthorized, err2.CodeUnauthorized, "Se requiere autenticación"))
 			return
 		}
+		roles := strings.Split(rolesClaim, ",")
 		isAccessible := false
 		if len(roles) < len(auths) || len(roles) == len(auths) {
 			for _, auth := range auths {
@@ -68,12 +46,6 @@ func RequireAuthorization(auths ...string)  gin.HandlerFunc {
 	}
 }
 
-func invalidRequest(c *gin.Context) {
-	c.JSON(http.StatusForbidden, gin.H{"error": "Invalid request, restricted endpoint"})
-	c.Abort()
-}
-
 func notPermission(c *gin.Context) {
-	c.JSON(http.StatusForbidden, gin.H{"error": "Dont have permission"})
-	c.Abort()
+	err2.Abort(c, err2.New(http.StatusForbidden, err2.CodeForbidden, "No tienes permiso para esta acción"))
 }
@@ -0,0 +1,16 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MaxBodySize corta la lectura del cuerpo al superar limit bytes; el binding
+// falla con *http.MaxBytesError y err.Validation lo convierte en un 413
+func MaxBodySize(limit int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		c.Next()
+	}
+}
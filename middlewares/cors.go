@@ -1,23 +1,21 @@
 package middlewares
 
 import (
+	"mgo-gin/config"
+
 	"github.com/gin-gonic/gin"
 	cors "github.com/rs/cors/wrapper/gin"
 )
 
-
-// NewCors return new gin handler fuc to handle CORS request
-func NewCors(allowedOrigins []string) gin.HandlerFunc {
+// NewCors return new gin handler fuc to handle CORS request. Los orígenes
+// admiten un comodín para subdominios (https://*.example.com).
+func NewCors(cfg config.CORSConfig) gin.HandlerFunc {
 	return cors.New(cors.Options{
-		AllowedOrigins: allowedOrigins,
-		AllowedMethods: []string{"GET", "POST", "PUT", "DELETE", "OPTIONS", "HEAD"},
-		AllowedHeaders: []string{
-			"Origin", "Host",
-			"Content-Type", "Content-Length",
-			"Accept-Encoding", "Accept-Language", "Accept",
-			"X-CSRF-Token", "Authorization", "X-Requested-With", "X-Access-Token",
-		},
-		ExposedHeaders:   []string{"Content-Length"},
-		AllowCredentials: true,
+		AllowedOrigins:   cfg.AllowedOrigins,
+		AllowedMethods:   cfg.AllowedMethods,
+		AllowedHeaders:   cfg.AllowedHeaders,
+		ExposedHeaders:   cfg.ExposedHeaders,
+		AllowCredentials: cfg.AllowCredentials,
+		MaxAge:           int(cfg.MaxAge.Seconds()),
 	})
 }
@@ -0,0 +1,73 @@
+package middlewares
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+
+	err2 "mgo-gin/utils/err"
+	"mgo-gin/utils/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/sirupsen/logrus"
+)
+
+var registerTagNames sync.Once
+
+// ErrorHandler renderiza el último error agregado con c.Error usando el formato
+// común de errores de la API
+func ErrorHandler() gin.HandlerFunc {
+	registerTagNames.Do(useFieldTagNames)
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+		last := c.Errors.Last().Err
+		var apiErr *err2.Error
+		if !errors.As(last, &apiErr) {
+			apiErr = err2.FromStatus(http.StatusInternalServerError, last)
+		}
+		if apiErr.RequestID == "" {
+			apiErr.RequestID = requestID(c)
+		}
+		if apiErr.Status >= http.StatusInternalServerError {
+			logger.FromGin(c).WithError(apiErr).WithFields(logrus.Fields{
+				"method": c.Request.Method,
+				"path":   c.Request.URL.Path,
+				"code":   apiErr.Code,
+			}).Error("request failed")
+		}
+		c.JSON(apiErr.Status, gin.H{"error": apiErr})
+	}
+}
+
+func requestID(c *gin.Context) string {
+	if id := c.GetString("request_id"); id != "" {
+		return id
+	}
+	return c.GetHeader("X-Request-ID")
+}
+
+// useFieldTagNames hace que los errores de validación usen el nombre del campo
+// en el formulario o JSON en vez del nombre del struct
+func useFieldTagNames() {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return
+	}
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		for _, tag := range []string{"form", "json"} {
+			name := strings.Split(field.Tag.Get(tag), ",")[0]
+			if name != "" && name != "-" {
+				return name
+			}
+		}
+		return field.Name
+	})
+}
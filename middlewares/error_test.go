@@ -0,0 +1,112 @@
+package middlewares
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	err2 "mgo-gin/utils/err"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type errorEnvelope struct {
+	Error struct {
+		Code      string `json:"code"`
+		Message   string `json:"message"`
+		Fields    []err2.FieldError
+		RequestID string `json:"request_id"`
+	} `json:"error"`
+}
+
+func serveWithErrorHandler(t *testing.T, handler gin.HandlerFunc, req *http.Request) (*httptest.ResponseRecorder, errorEnvelope) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(NewRequestID(), ErrorHandler())
+	router.POST("/", handler)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var body errorEnvelope
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("response is not JSON: %v: %s", err, w.Body.String())
+	}
+	return w, body
+}
+
+func TestErrorHandlerEnvelope(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         error
+		wantStatus  int
+		wantCode    string
+		wantMessage string
+	}{
+		{"api error", err2.New(http.StatusConflict, err2.CodeConflict, "ya reportaste esta reseña"), http.StatusConflict, err2.CodeConflict, "ya reportaste esta reseña"},
+		{"repository not found", err2.FromStatus(http.StatusNotFound, mongo.ErrNoDocuments), http.StatusNotFound, err2.CodeNotFound, "Recurso no encontrado"},
+		{"plain error is a 500", errors.New("dial tcp: connection refused"), http.StatusInternalServerError, err2.CodeInternal, "Error interno del servidor"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			req.Header.Set(RequestIDHeader, "req-123")
+			w, body := serveWithErrorHandler(t, func(c *gin.Context) { err2.Abort(c, tt.err) }, req)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if body.Error.Code != tt.wantCode || body.Error.Message != tt.wantMessage {
+				t.Errorf("got %s %q, want %s %q", body.Error.Code, body.Error.Message, tt.wantCode, tt.wantMessage)
+			}
+			if body.Error.RequestID != "req-123" {
+				t.Errorf("request_id = %q", body.Error.RequestID)
+			}
+		})
+	}
+}
+
+func TestErrorHandlerValidationFields(t *testing.T) {
+	type payload struct {
+		Title string  `json:"title" binding:"required"`
+		Price float64 `json:"price" binding:"gt=0"`
+	}
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"price": -1}`))
+	req.Header.Set("Content-Type", "application/json")
+	w, body := serveWithErrorHandler(t, func(c *gin.Context) {
+		var p payload
+		if err := c.ShouldBindJSON(&p); err != nil {
+			err2.Abort(c, err2.Validation(err))
+		}
+	}, req)
+
+	if w.Code != http.StatusBadRequest || body.Error.Code != err2.CodeValidation {
+		t.Fatalf("got %d %s", w.Code, body.Error.Code)
+	}
+	want := map[string]string{"title": "es obligatorio", "price": "debe ser mayor que 0"}
+	if len(body.Error.Fields) != len(want) {
+		t.Fatalf("fields = %+v", body.Error.Fields)
+	}
+	for _, field := range body.Error.Fields {
+		if want[field.Field] != field.Message {
+			t.Errorf("field %s: %q, want %q", field.Field, field.Message, want[field.Field])
+		}
+	}
+	if body.Error.RequestID == "" {
+		t.Error("a request id is generated when the client sends none")
+	}
+}
+
+func TestErrorHandlerLeavesWrittenResponses(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	w, body := serveWithErrorHandler(t, func(c *gin.Context) {
+		c.JSON(http.StatusAccepted, gin.H{"error": gin.H{"code": "ya escrito"}})
+		_ = c.Error(errors.New("después de responder"))
+	}, req)
+	if w.Code != http.StatusAccepted || body.Error.Code != "ya escrito" {
+		t.Errorf("got %d %s", w.Code, body.Error.Code)
+	}
+}
@@ -0,0 +1,35 @@
+package middlewares
+
+import (
+	"mgo-gin/utils/logger"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// NewLogger reemplaza a gin.Logger con una línea estructurada por petición
+func NewLogger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		entry := logger.FromGin(c).WithFields(logrus.Fields{
+			"method":     c.Request.Method,
+			"path":       c.Request.URL.Path,
+			"route":      c.FullPath(),
+			"status":     c.Writer.Status(),
+			"latency_ms": time.Since(start).Milliseconds(),
+			"client_ip":  c.ClientIP(),
+			"bytes":      c.Writer.Size(),
+		})
+		switch status := c.Writer.Status(); {
+		case status >= 500:
+			entry.Error("request completed")
+		case status >= 400:
+			entry.Warn("request completed")
+		default:
+			entry.Info("request completed")
+		}
+	}
+}
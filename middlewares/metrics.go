@@ -0,0 +1,25 @@
+package middlewares
+
+import (
+	"mgo-gin/utils/metrics"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NewMetrics cuenta las peticiones y mide su latencia por ruta. Se usa la ruta
+// registrada (/product/:productid) para no crear una serie por cada id.
+func NewMetrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		metrics.HTTPRequests.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		metrics.HTTPDuration.WithLabelValues(c.Request.Method, route).Observe(time.Since(start).Seconds())
+	}
+}
@@ -0,0 +1,106 @@
+package middlewares
+
+import (
+	"context"
+	"math"
+	"mgo-gin/config"
+	"sync"
+	"time"
+)
+
+// RateLimitResult es el estado del bucket después de intentar consumir un token
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration // cuánto falta para el siguiente token si no se permitió
+	Reset      time.Duration // cuánto falta para que el bucket vuelva a estar lleno
+}
+
+// RateLimitStore guarda los buckets. MemoryRateLimitStore sirve para una sola
+// instancia; con varias réplicas hace falta una implementación compartida
+// (por ejemplo Redis) para que el límite sea global.
+type RateLimitStore interface {
+	Take(ctx context.Context, key string, policy config.RateLimitPolicy) (RateLimitResult, error)
+}
+
+type bucket struct {
+	tokens   float64
+	last     time.Time
+	rate     float64 // tokens por segundo
+	capacity float64
+}
+
+func (b *bucket) refill(now time.Time) float64 {
+	return math.Min(b.capacity, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+}
+
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		buckets: map[string]*bucket{},
+	}
+}
+
+// sweepInterval cada cuánto se eliminan los buckets llenos, que equivalen a no
+// tener bucket, para que la memoria no crezca con cada IP que pasa
+const sweepInterval = time.Minute
+
+func (s *MemoryRateLimitStore) Take(_ context.Context, key string, policy config.RateLimitPolicy) (RateLimitResult, error) {
+	capacity := float64(burst(policy))
+	rate := float64(policy.Requests) / policy.Period.Seconds()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, last: now}
+		s.buckets[key] = b
+	} else {
+		b.tokens = b.refill(now)
+		b.last = now
+	}
+	b.rate, b.capacity = rate, capacity
+
+	result := RateLimitResult{Limit: burst(policy)}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = secondsToDuration((1 - b.tokens) / rate)
+	}
+	result.Remaining = int(b.tokens)
+	result.Reset = secondsToDuration((capacity - b.tokens) / rate)
+
+	if now.Sub(s.lastSweep) > sweepInterval {
+		s.sweep(now)
+		s.lastSweep = now
+	}
+	return result, nil
+}
+
+func (s *MemoryRateLimitStore) sweep(now time.Time) {
+	for key, b := range s.buckets {
+		if b.refill(now) >= b.capacity {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+func burst(policy config.RateLimitPolicy) int {
+	if policy.Burst > 0 {
+		return policy.Burst
+	}
+	return policy.Requests
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(math.Ceil(seconds * float64(time.Second)))
+}
@@ -0,0 +1,76 @@
+package middlewares
+
+import (
+	"context"
+	"mgo-gin/config"
+	"testing"
+	"time"
+)
+
+func TestMemoryRateLimitStoreTake(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      config.RateLimitPolicy
+		takes       int
+		wantAllowed int
+	}{
+		{"requests are the burst by default", config.RateLimitPolicy{Requests: 3, Period: time.Hour}, 5, 3},
+		{"explicit burst", config.RateLimitPolicy{Requests: 3, Period: time.Hour, Burst: 1}, 5, 1},
+		{"within the limit", config.RateLimitPolicy{Requests: 10, Period: time.Hour}, 4, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewMemoryRateLimitStore()
+			allowed := 0
+			var last RateLimitResult
+			for i := 0; i < tt.takes; i++ {
+				result, err := store.Take(context.Background(), "key", tt.policy)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if result.Allowed {
+					allowed++
+				}
+				last = result
+			}
+			if allowed != tt.wantAllowed {
+				t.Errorf("allowed %d of %d requests, want %d", allowed, tt.takes, tt.wantAllowed)
+			}
+			if last.Limit != burst(tt.policy) {
+				t.Errorf("Limit = %d, want %d", last.Limit, burst(tt.policy))
+			}
+			if !last.Allowed && last.RetryAfter <= 0 {
+				t.Errorf("rejected request without RetryAfter: %+v", last)
+			}
+		})
+	}
+}
+
+func TestMemoryRateLimitStoreKeysAreIndependent(t *testing.T) {
+	store := NewMemoryRateLimitStore()
+	policy := config.RateLimitPolicy{Requests: 1, Period: time.Hour}
+	for _, key := range []string{"a", "b"} {
+		if result, _ := store.Take(context.Background(), key, policy); !result.Allowed {
+			t.Errorf("first request for %s was rejected", key)
+		}
+	}
+	if result, _ := store.Take(context.Background(), "a", policy); result.Allowed {
+		t.Error("second request for a was allowed")
+	}
+}
+
+func TestMemoryRateLimitStoreRefills(t *testing.T) {
+	store := NewMemoryRateLimitStore()
+	policy := config.RateLimitPolicy{Requests: 1, Period: 20 * time.Millisecond}
+	if result, _ := store.Take(context.Background(), "key", policy); !result.Allowed {
+		t.Fatal("first request was rejected")
+	}
+	result, _ := store.Take(context.Background(), "key", policy)
+	if result.Allowed {
+		t.Fatal("second request was allowed before the refill")
+	}
+	time.Sleep(result.RetryAfter)
+	if result, _ := store.Take(context.Background(), "key", policy); !result.Allowed {
+		t.Errorf("request after RetryAfter was rejected: %+v", result)
+	}
+}
@@ -1,18 +1,83 @@
 package middlewares
 
 import (
-	nice "github.com/ekyoung/gin-nice-recovery"
+	"errors"
+	"html/template"
+	"mgo-gin/utils/logger"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"syscall"
+
+	err2 "mgo-gin/utils/err"
+
 	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
 )
 
-//func NewRecovery
-func NewRecovery() gin.HandlerFunc {
-	return nice.Recovery(recoveryHandler)
+// NewRecovery atrapa los panics de los handlers. Las rutas /api y los clientes
+// que piden JSON reciben el formato común de errores; los navegadores, la
+// página de errorTemplate. El stack trace solo va al log.
+func NewRecovery(errorTemplate string) gin.HandlerFunc {
+	page, err := template.ParseFiles(errorTemplate)
+	if err != nil {
+		logrus.WithError(err).Warn("Error page template not available, panics will be rendered as JSON")
+	}
+	return func(c *gin.Context) {
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			entry := logger.FromGin(c).WithFields(logrus.Fields{
+				"panic":     recovered,
+				"method":    c.Request.Method,
+				"path":      c.Request.URL.Path,
+				"route":     c.FullPath(),
+				"query":     c.Request.URL.RawQuery,
+				"client_ip": c.ClientIP(),
+				"stack":     string(debug.Stack()),
+			})
+			if brokenPipe(recovered) {
+				// El cliente ya se fue: no hay a quién responder
+				entry.Warn("panic writing response to closed connection")
+				c.Abort()
+				return
+			}
+			entry.Error("panic recovered")
+
+			apiErr := err2.New(http.StatusInternalServerError, err2.CodeInternal, "Error interno del servidor")
+			apiErr.RequestID = requestID(c)
+			if page != nil && wantsHTML(c) {
+				c.Status(apiErr.Status)
+				c.Header("Content-Type", "text/html; charset=utf-8")
+				if err := page.Execute(c.Writer, gin.H{
+					"title":      "Algo salió mal",
+					"err":        apiErr.Message,
+					"request_id": apiErr.RequestID,
+				}); err != nil {
+					logger.FromGin(c).WithError(err).Error("Error rendering error page")
+				}
+				c.Abort()
+				return
+			}
+			c.AbortWithStatusJSON(apiErr.Status, gin.H{"error": apiErr})
+		}()
+		c.Next()
+	}
+}
+
+func wantsHTML(c *gin.Context) bool {
+	if strings.HasPrefix(c.Request.URL.Path, "/api/") {
+		return false
+	}
+	return c.NegotiateFormat(gin.MIMEJSON, gin.MIMEHTML) == gin.MIMEHTML
 }
 
-func recoveryHandler(c *gin.Context, err interface{}) {
-	c.HTML(500, "err.tmpl", gin.H{
-		"title": "Error",
-		"err": err,
-	})
+func brokenPipe(recovered interface{}) bool {
+	err, ok := recovered.(error)
+	if !ok {
+		return false
+	}
+	return errors.Is(err, http.ErrAbortHandler) || errors.Is(err, syscall.EPIPE) || errors.Is(err, syscall.ECONNRESET)
 }
@@ -0,0 +1,115 @@
+package middlewares
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newRecoveryRouter(t *testing.T, panicValue interface{}) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(NewRequestID(), NewRecovery("../error.tmpl"))
+	handler := func(c *gin.Context) { panic(panicValue) }
+	router.GET("/api/v1/boom", handler)
+	router.GET("/boom", handler)
+	return router
+}
+
+func TestRecoveryRendersJSONForTheAPI(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/boom", nil)
+	// Las rutas /api responden JSON aunque el cliente prefiera HTML
+	req.Header.Set("Accept", "text/html")
+	req.Header.Set(RequestIDHeader, "req-123")
+	w := httptest.NewRecorder()
+	newRecoveryRouter(t, "secreto interno").ServeHTTP(w, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want 500", w.Code)
+	}
+	var body errorEnvelope
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("response is not JSON: %v: %s", err, w.Body.String())
+	}
+	if body.Error.Code != "internal_error" || body.Error.Message != "Error interno del servidor" || body.Error.RequestID != "req-123" {
+		t.Errorf("unexpected envelope: %+v", body.Error)
+	}
+	if strings.Contains(w.Body.String(), "secreto interno") {
+		t.Error("the panic value leaked to the client")
+	}
+}
+
+func TestRecoveryRendersHTMLForBrowsers(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/boom", nil)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+	req.Header.Set(RequestIDHeader, "req-456")
+	w := httptest.NewRecorder()
+	newRecoveryRouter(t, fmt.Errorf("secreto interno")).ServeHTTP(w, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want 500", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	page := w.Body.String()
+	if !strings.Contains(page, "Algo salió mal") || !strings.Contains(page, "req-456") {
+		t.Errorf("error page without title or request id: %s", page)
+	}
+	if strings.Contains(page, "secreto interno") {
+		t.Error("the panic value leaked to the client")
+	}
+}
+
+func TestRecoveryFallsBackToJSONWithoutTemplate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(NewRecovery("does-not-exist.tmpl"))
+	router.GET("/boom", func(c *gin.Context) { panic("boom") })
+
+	req := httptest.NewRequest(http.MethodGet, "/boom", nil)
+	req.Header.Set("Accept", "text/html")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusInternalServerError || !strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+		t.Errorf("got %d %q, want a JSON 500", w.Code, w.Header().Get("Content-Type"))
+	}
+}
+
+func TestRecoveryBrokenPipe(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  bool
+	}{
+		{syscall.EPIPE, true},
+		{fmt.Errorf("write: %w", syscall.ECONNRESET), true},
+		{http.ErrAbortHandler, true},
+		{"broken pipe", false},
+		{fmt.Errorf("otro error"), false},
+	}
+	for _, tt := range tests {
+		if got := brokenPipe(tt.value); got != tt.want {
+			t.Errorf("brokenPipe(%v) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestRecoveryPassesThroughWithoutPanic(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(NewRecovery("../error.tmpl"))
+	router.GET("/ok", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ok", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "ok" {
+		t.Errorf("got %d %q", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,29 @@
+package middlewares
+
+import (
+	"mgo-gin/utils/logger"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+// Solo se propaga un X-Request-ID entrante si es corto y seguro para logs
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// NewRequestID reutiliza el X-Request-ID del cliente o genera uno nuevo, lo
+// devuelve en la respuesta y lo deja en el contexto para los logs
+func NewRequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = uuid.NewString()
+		}
+		c.Set("request_id", id)
+		c.Header(RequestIDHeader, id)
+		c.Request = c.Request.WithContext(logger.WithRequestID(c.Request.Context(), id))
+		c.Next()
+	}
+}
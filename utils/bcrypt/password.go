@@ -8,7 +8,7 @@ import (
 func HashPassword(password string) string {
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
-		logrus.Print(err)
+		logrus.WithError(err).Error("Error hashing password")
 	}
 	return string(hashedPassword)
 }
@@ -1,8 +1,197 @@
 package err
 
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Códigos estables que los clientes pueden usar para distinguir errores
+const (
+	CodeBadRequest    = "bad_request"
+	CodeValidation    = "validation_failed"
+	CodeUnauthorized  = "unauthorized"
+	CodeForbidden     = "forbidden"
+	CodeNotFound      = "not_found"
+	CodeConflict      = "conflict"
+	CodeUnprocessable = "unprocessable_entity"
+	CodeRateLimited   = "rate_limited"
+	CodePrecondition  = "precondition_failed"
+	CodeTooLarge      = "payload_too_large"
+	CodeUnsupported   = "unsupported_media_type"
+	CodeInternal      = "internal_error"
+)
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Error es el único formato de error que devuelve la API:
+// {"error": {"code": ..., "message": ..., "fields": [...], "request_id": ...}}
+type Error struct {
+	Status    int          `json:"-"`
+	Code      string       `json:"code"`
+	Message   string       `json:"message"`
+	Fields    []FieldError `json:"fields,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+	Err       error        `json:"-"`
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Message, e.Err)
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func New(status int, code string, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+// FromStatus envuelve un error de repositorio con el código HTTP que devolvió.
+// Los mensajes del driver, del contexto y de los errores 5xx nunca llegan al
+// cliente: se reemplazan por uno genérico y el original queda en Err para el log.
+func FromStatus(status int, err error) *Error {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+	if status < http.StatusBadRequest {
+		status = http.StatusInternalServerError
+	}
+	message := GetErrorMessage(err)
+	switch {
+	case errors.Is(err, mongo.ErrNoDocuments):
+		message = "Recurso no encontrado"
+	case status >= http.StatusInternalServerError || message == "" || isInternal(err):
+		message = defaultMessage(status)
+	}
+	return &Error{Status: status, Code: codeFromStatus(status), Message: message, Err: err}
+}
+
+// isInternal indica si el error viene del driver de Mongo o del contexto, cuyos
+// mensajes están en inglés y describen detalles internos
+func isInternal(err error) bool {
+	var (
+		commandErr   mongo.CommandError
+		writeErr     mongo.WriteException
+		bulkWriteErr mongo.BulkWriteException
+	)
+	return errors.Is(err, context.Canceled) ||
+		errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, mongo.ErrClientDisconnected) ||
+		errors.As(err, &commandErr) ||
+		errors.As(err, &writeErr) ||
+		errors.As(err, &bulkWriteErr)
+}
+
+// Validation convierte los errores de binding de gin en errores por campo
+func Validation(err error) *Error {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return &Error{Status: http.StatusRequestEntityTooLarge, Code: CodeTooLarge, Message: fmt.Sprintf("La petición supera el máximo de %d MB", maxBytesErr.Limit>>20), Err: err}
+	}
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		return &Error{Status: http.StatusBadRequest, Code: CodeBadRequest, Message: "Cuerpo de la petición inválido: " + err.Error(), Err: err}
+	}
+	fields := []FieldError{}
+	for _, fe := range validationErrors {
+		fields = append(fields, FieldError{Field: fe.Field(), Message: fieldMessage(fe)})
+	}
+	return &Error{Status: http.StatusBadRequest, Code: CodeValidation, Message: "Datos inválidos", Fields: fields, Err: err}
+}
+
+// Abort detiene la cadena de handlers y deja el error para que lo renderice
+// middlewares.ErrorHandler
+func Abort(c *gin.Context, err error) {
+	c.Abort()
+	_ = c.Error(err)
+}
+
 func GetErrorMessage(err error) string {
 	if err != nil {
 		return err.Error()
 	}
 	return ""
 }
+
+func fieldMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "es obligatorio"
+	case "min":
+		return "debe ser mayor o igual a " + fe.Param()
+	case "max":
+		return "debe ser menor o igual a " + fe.Param()
+	case "gt":
+		return "debe ser mayor que " + fe.Param()
+	case "oneof":
+		return "debe ser uno de: " + fe.Param()
+	}
+	return "no es válido"
+}
+
+func codeFromStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeBadRequest
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusUnprocessableEntity:
+		return CodeUnprocessable
+	case http.StatusTooManyRequests:
+		return CodeRateLimited
+	case http.StatusPreconditionFailed:
+		return CodePrecondition
+	case http.StatusRequestEntityTooLarge:
+		return CodeTooLarge
+	case http.StatusUnsupportedMediaType:
+		return CodeUnsupported
+	}
+	if status >= http.StatusInternalServerError {
+		return CodeInternal
+	}
+	return CodeBadRequest
+}
+
+func defaultMessage(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "Petición inválida"
+	case http.StatusUnauthorized:
+		return "No autenticado"
+	case http.StatusForbidden:
+		return "No tienes permiso para esta acción"
+	case http.StatusNotFound:
+		return "Recurso no encontrado"
+	case http.StatusConflict:
+		return "La petición entra en conflicto con el estado actual"
+	case http.StatusPreconditionFailed:
+		return "El recurso cambió desde la última lectura"
+	case http.StatusTooManyRequests:
+		return "Demasiadas peticiones, intenta más tarde"
+	case http.StatusGatewayTimeout:
+		return "La operación tardó demasiado"
+	}
+	if status >= http.StatusInternalServerError {
+		return "Error interno del servidor"
+	}
+	return "La petición no se pudo procesar"
+}
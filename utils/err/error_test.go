@@ -0,0 +1,56 @@
+package err
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestFromStatus(t *testing.T) {
+	apiErr := New(http.StatusConflict, CodeConflict, "ya existe")
+	tests := []struct {
+		name        string
+		status      int
+		err         error
+		wantStatus  int
+		wantCode    string
+		wantMessage string
+	}{
+		{"repository message is kept for 4xx", http.StatusBadRequest, errors.New("id inválido"), http.StatusBadRequest, CodeBadRequest, "id inválido"},
+		{"no documents", http.StatusNotFound, mongo.ErrNoDocuments, http.StatusNotFound, CodeNotFound, "Recurso no encontrado"},
+		{"wrapped no documents", http.StatusNotFound, fmt.Errorf("buscando producto: %w", mongo.ErrNoDocuments), http.StatusNotFound, CodeNotFound, "Recurso no encontrado"},
+		{"driver write error", http.StatusConflict, mongo.WriteException{WriteErrors: mongo.WriteErrors{{Code: 11000, Message: "E11000 duplicate key error collection: test.users"}}}, http.StatusConflict, CodeConflict, "La petición entra en conflicto con el estado actual"},
+		{"driver command error", http.StatusBadRequest, mongo.CommandError{Code: 2, Message: "unknown operator: $foo"}, http.StatusBadRequest, CodeBadRequest, "Petición inválida"},
+		{"deadline", http.StatusGatewayTimeout, context.DeadlineExceeded, http.StatusGatewayTimeout, CodeInternal, "La operación tardó demasiado"},
+		{"canceled", 499, context.Canceled, 499, CodeBadRequest, "La petición no se pudo procesar"},
+		{"5xx hides the message", http.StatusInternalServerError, errors.New("connection refused 10.0.0.3:27017"), http.StatusInternalServerError, CodeInternal, "Error interno del servidor"},
+		{"success status becomes 500", http.StatusOK, errors.New("algo"), http.StatusInternalServerError, CodeInternal, "Error interno del servidor"},
+		{"empty message", http.StatusForbidden, errors.New(""), http.StatusForbidden, CodeForbidden, "No tienes permiso para esta acción"},
+		{"api error passes through", http.StatusInternalServerError, fmt.Errorf("envuelto: %w", apiErr), http.StatusConflict, CodeConflict, "ya existe"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := FromStatus(tt.status, tt.err)
+			if got.Status != tt.wantStatus || got.Code != tt.wantCode || got.Message != tt.wantMessage {
+				t.Errorf("got %d %s %q, want %d %s %q", got.Status, got.Code, got.Message, tt.wantStatus, tt.wantCode, tt.wantMessage)
+			}
+			if got != apiErr && got.Err == nil {
+				t.Errorf("the original error is not kept for the logs")
+			}
+		})
+	}
+}
+
+func TestValidationTooLarge(t *testing.T) {
+	got := Validation(fmt.Errorf("leyendo el cuerpo: %w", &http.MaxBytesError{Limit: 10 << 20}))
+	if got.Status != http.StatusRequestEntityTooLarge || got.Code != CodeTooLarge {
+		t.Errorf("got %d %s", got.Status, got.Code)
+	}
+	if got.Message != "La petición supera el máximo de 10 MB" {
+		t.Errorf("message = %q", got.Message)
+	}
+}
@@ -0,0 +1,68 @@
+package logger
+
+import (
+	"context"
+	"mgo-gin/config"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type contextKey string
+
+const (
+	requestIDKey contextKey = "request_id"
+	userIDKey    contextKey = "user_id"
+)
+
+// Setup configura el logger global de logrus: JSON por defecto, texto para desarrollo local
+func Setup(cfg config.LogConfig) {
+	logrus.SetOutput(os.Stdout)
+	if cfg.Format == config.LogFormatText {
+		logrus.SetFormatter(&logrus.TextFormatter{FullTimestamp: true})
+	} else {
+		logrus.SetFormatter(&logrus.JSONFormatter{})
+	}
+	level, err := logrus.ParseLevel(cfg.Level)
+	if err != nil {
+		level = logrus.InfoLevel
+	}
+	logrus.SetLevel(level)
+}
+
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey, requestID)
+}
+
+func WithUserID(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, userIDKey, userID)
+}
+
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// FromContext devuelve un logger con el request id y el user id guardados en ctx
+func FromContext(ctx context.Context) *logrus.Entry {
+	fields := logrus.Fields{}
+	if ctx != nil {
+		if id, ok := ctx.Value(requestIDKey).(string); ok && id != "" {
+			fields["request_id"] = id
+		}
+		if id, ok := ctx.Value(userIDKey).(string); ok && id != "" {
+			fields["user_id"] = id
+		}
+		if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+			fields["trace_id"] = span.TraceID().String()
+		}
+	}
+	return logrus.WithFields(fields)
+}
+
+// FromGin es FromContext para handlers de gin
+func FromGin(c *gin.Context) *logrus.Entry {
+	return FromContext(c.Request.Context())
+}
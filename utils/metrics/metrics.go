@@ -0,0 +1,140 @@
+package metrics
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+const namespace = "naysdream"
+
+var (
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by method, route and status code.",
+	}, []string{"method", "route", "status"})
+
+	HTTPDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method and route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	ProductsCreated = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "products_created_total",
+		Help:      "Products created.",
+	})
+
+	ReviewsAdded = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reviews_added_total",
+		Help:      "New product reviews (updates to an existing review are not counted).",
+	})
+
+	Logins = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "logins_total",
+		Help:      "Login attempts by result (succeeded or failed).",
+	}, []string{"result"})
+
+	RateLimited = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limited_total",
+		Help:      "Requests rejected with 429 by rate limit policy.",
+	}, []string{"policy"})
+
+	ImageJobs = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "image_jobs_total",
+		Help:      "Processed image jobs by result (done, retried or failed).",
+	}, []string{"result"})
+
+	OrphanedImagesDeleted = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "orphaned_images_deleted_total",
+		Help:      "Stored images deleted by the reconciler because no product used them.",
+	})
+
+	UploadDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "storage_upload_duration_seconds",
+		Help:      "Duration of image uploads by storage backend (local, s3 or cloudinary).",
+		Buckets:   []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2, 5, 10, 30},
+	}, []string{"backend"})
+
+	UploadFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "storage_upload_failures_total",
+		Help:      "Failed image uploads by storage backend.",
+	}, []string{"backend"})
+
+	MongoDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "mongo_command_duration_seconds",
+		Help:      "MongoDB command latency by command, collection and outcome.",
+		Buckets:   []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 5},
+	}, []string{"command", "collection", "status"})
+)
+
+const (
+	LoginSucceeded = "succeeded"
+	LoginFailed    = "failed"
+)
+
+const (
+	JobDone    = "done"
+	JobRetried = "retried"
+	JobFailed  = "failed"
+)
+
+// Handler expone las métricas en formato de texto de Prometheus
+func Handler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.Handler())
+}
+
+// ObserveUpload registra la duración de una subida al storage backend y si falló
+func ObserveUpload(backend string, start time.Time, err error) {
+	UploadDuration.WithLabelValues(backend).Observe(time.Since(start).Seconds())
+	if err != nil {
+		UploadFailures.WithLabelValues(backend).Inc()
+	}
+}
+
+// MongoMonitor mide la duración de cada comando que envían los repositorios.
+// La colección solo viene en el evento de inicio, así que se guarda por RequestID.
+func MongoMonitor() *event.CommandMonitor {
+	var collections sync.Map
+	finished := func(e event.CommandFinishedEvent, status string) {
+		collection := "unknown"
+		if value, ok := collections.LoadAndDelete(e.RequestID); ok {
+			collection = value.(string)
+		}
+		MongoDuration.WithLabelValues(e.CommandName, collection, status).Observe(time.Duration(e.DurationNanos).Seconds())
+	}
+	return &event.CommandMonitor{
+		Started: func(_ context.Context, e *event.CommandStartedEvent) {
+			collection := "none"
+			if value, err := e.Command.LookupErr(e.CommandName); err == nil {
+				if name, ok := value.StringValueOK(); ok {
+					collection = name
+				}
+			}
+			collections.Store(e.RequestID, collection)
+		},
+		Succeeded: func(_ context.Context, e *event.CommandSucceededEvent) {
+			finished(e.CommandFinishedEvent, "ok")
+		},
+		Failed: func(_ context.Context, e *event.CommandFailedEvent) {
+			finished(e.CommandFinishedEvent, "error")
+		},
+	}
+}
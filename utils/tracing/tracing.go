@@ -0,0 +1,115 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"mgo-gin/config"
+	"os"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/event"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "mgo-gin"
+
+// Setup instala el TracerProvider global según la configuración. Con el
+// exportador "none" no se registra nada y los spans no tienen costo.
+// La función devuelta vacía los spans pendientes al apagar el servidor.
+func Setup(ctx context.Context, cfg config.TracingConfig, env string) (func(context.Context) error, error) {
+	noop := func(context.Context) error { return nil }
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch cfg.Exporter {
+	case config.TracingExporterNone:
+		return noop, nil
+	case config.TracingExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout), stdouttrace.WithPrettyPrint())
+	case config.TracingExporterOTLP:
+		opts := []otlptracehttp.Option{}
+		if cfg.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpointURL(cfg.Endpoint))
+		}
+		exporter, err = otlptracehttp.New(ctx, opts...)
+	default:
+		return noop, fmt.Errorf("unknown tracing exporter %q", cfg.Exporter)
+	}
+	if err != nil {
+		return noop, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(cfg.ServiceName),
+		semconv.DeploymentEnvironment(env),
+	))
+	if err != nil {
+		return noop, err
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return provider.Shutdown, nil
+}
+
+// Start abre un span hijo del que venga en ctx
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End cierra el span marcándolo como fallido si err no es nil
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// MongoMonitor crea un span por cada comando enviado a MongoDB. El span se abre
+// en el evento de inicio y se cierra en el de fin, que se relacionan por RequestID.
+func MongoMonitor() *event.CommandMonitor {
+	var spans sync.Map
+	finish := func(requestID int64, err error) {
+		if value, ok := spans.LoadAndDelete(requestID); ok {
+			End(value.(trace.Span), err)
+		}
+	}
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, e *event.CommandStartedEvent) {
+			attrs := []attribute.KeyValue{
+				semconv.DBSystemMongoDB,
+				semconv.DBNamespace(e.DatabaseName),
+				semconv.DBOperationName(e.CommandName),
+			}
+			if value, err := e.Command.LookupErr(e.CommandName); err == nil {
+				if collection, ok := value.StringValueOK(); ok {
+					attrs = append(attrs, semconv.DBCollectionName(collection))
+				}
+			}
+			_, span := otel.Tracer(tracerName).Start(ctx, "mongo."+e.CommandName,
+				trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+			spans.Store(e.RequestID, span)
+		},
+		Succeeded: func(_ context.Context, e *event.CommandSucceededEvent) {
+			finish(e.RequestID, nil)
+		},
+		Failed: func(_ context.Context, e *event.CommandFailedEvent) {
+			finish(e.RequestID, fmt.Errorf("%s", e.Failure))
+		},
+	}
+}